@@ -7,42 +7,54 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/coolapso/prometheus-twitch-exporter/internal/collectors"
+	"github.com/coolapso/prometheus-twitch-exporter/internal/config"
 	"github.com/coolapso/prometheus-twitch-exporter/internal/httpServer"
 	"github.com/coolapso/prometheus-twitch-exporter/internal/slogLogger"
-	helix "github.com/nicklaw5/helix/v2"
+	"gopkg.in/yaml.v3"
 
 	"github.com/prometheus/common/version"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "twitch-exporter",
 	Short: "Exporter metrics from twitch to prometheus",
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		c, err := config.Resolve(cmd.Flags(), os.LookupEnv)
+		if err != nil {
+			return err
+		}
+		resolved = c
+
+		if printConfig {
+			return nil
+		}
+
+		settings = c.Settings()
 		return checkCoreSettings()
 	},
 
-	Run: func(cmd *cobra.Command, args []string) {
-		exporter()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if printConfig {
+			encoder := yaml.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent(2)
+			return encoder.Encode(resolved.Redacted())
+		}
+
+		exporter(cmd)
+		return nil
 	},
 }
 
-const (
-	defaultLogLevel        = "info"
-	defaultLogFormat       = "text"
-	defaultMetricsPath     = "/metrics"
-	defaultListenPort      = "9184"
-	defaultAddress         = "localhost"
-	defaultTwitchUserToken = false
-)
-
 var (
-	settings       collectors.Settings
-	twitchChannels []string
-	Version        = "DEV"
+	resolved    *config.Config
+	settings    *collectors.Settings
+	printConfig bool
+	Version     = "DEV"
 )
 
 func Execute() {
@@ -53,77 +65,12 @@ func Execute() {
 }
 
 func init() {
-	viper.AutomaticEnv()
-	viper.SetDefault("LOG_LEVEL", defaultLogLevel)
-	viper.SetDefault("LOG_FORMAT", defaultLogFormat)
-	viper.SetDefault("METRICS_PATH", defaultMetricsPath)
-	viper.SetDefault("LISTEN_PORT", defaultListenPort)
-	viper.SetDefault("ADDRESS", defaultAddress)
-	viper.SetDefault("TWITCH_CHANNELS", nil)
-	viper.SetDefault("TWITCH_USER", "")
-	viper.SetDefault("TWITCH_USER_TOKEN", defaultTwitchUserToken)
-	viper.SetDefault("TWITCH_CLIENT_ID", "")
-	viper.SetDefault("TWITCH_CLIENT_SECRET", "")
-	viper.SetDefault("TWITCH_ACCESS_TOKEN", "")
-	viper.SetDefault("TWITCH_REFRESH_TOKEN", "")
-
-	rootCmd.Flags().StringVar(&settings.LogLevel, "log.level", defaultLogLevel, "Exporter log level")
-	_ = viper.BindPFlag("log.level", rootCmd.Flags().Lookup("LOG_LEVEL"))
-
-	rootCmd.Flags().StringVar(&settings.LogFormat, "log.format", defaultLogFormat, "Exporter log format, text or json")
-	_ = viper.BindPFlag("log.format", rootCmd.Flags().Lookup("LOG_FORMAT"))
-
-	rootCmd.Flags().StringVar(&settings.MetricsPath, "metrics.path", defaultMetricsPath, "Path to expose metrics at")
-	_ = viper.BindPFlag("metrics.path", rootCmd.Flags().Lookup("METRICS_PATH"))
-
-	rootCmd.Flags().StringVar(&settings.ListenPort, "listen.port", defaultListenPort, "Port to listen at")
-	_ = viper.BindPFlag("listen.port", rootCmd.Flags().Lookup("LISTEN_PORT"))
-
-	rootCmd.Flags().StringVar(&settings.Address, "address", defaultAddress, "The address to access the exporter used for oauth redirect uri")
-	_ = viper.BindPFlag("address", rootCmd.Flags().Lookup("ADDRESS"))
-
-	rootCmd.Flags().StringSliceVar(&twitchChannels, "twitch.channels", nil, "List of channels to get basic metrics from")
-	_ = viper.BindPFlag("twitch.channels", rootCmd.Flags().Lookup("TWITCH_CHANNELS"))
-
-	rootCmd.Flags().StringVar(&settings.User.Name, "twitch.user", "", "The user associated with the user token to get extra metrics from")
-	_ = viper.BindPFlag("twitch.user", rootCmd.Flags().Lookup("TWITCH_USER"))
-
-	rootCmd.Flags().BoolVar(&settings.UserToken, "user.token", false, "If going to use the provided token as a user token")
-	_ = viper.BindPFlag("user.token", rootCmd.Flags().Lookup("TWITCH_USER_TOKEN"))
-
-	rootCmd.Flags().StringVar(&settings.ApiSettings.Options.ClientID, "client.id", "", "twitch client id")
-	_ = viper.BindPFlag("client.id", rootCmd.Flags().Lookup("TWITCH_CLIENT_ID"))
-
-	rootCmd.Flags().StringVar(&settings.ApiSettings.Options.ClientSecret, "client.secret", "", "twitch client secret")
-	_ = viper.BindPFlag("client.secret", rootCmd.Flags().Lookup("TWITCH_CLIENT_SECRET"))
-
-	rootCmd.Flags().StringVar(&settings.ApiSettings.Options.ClientSecret, "access.token", "", "twitch user access token")
-	_ = viper.BindPFlag("access.token", rootCmd.Flags().Lookup("TWITCH_ACCESS_TOKEN"))
-
-	rootCmd.Flags().StringVar(&settings.ApiSettings.Options.ClientSecret, "refresh.token", "", "twitch refresh token")
-	_ = viper.BindPFlag("refresh.token", rootCmd.Flags().Lookup("TWITCH_REFRESH_TOKEN"))
-
-	settings.LogLevel = viper.GetString("LOG_LEVEL")
-	settings.LogFormat = viper.GetString("LOG_FORMAT")
-	settings.MetricsPath = viper.GetString("METRICS_PATH")
-	settings.ListenPort = viper.GetString("LISTEN_PORT")
-	settings.Address = viper.GetString("ADDRESS")
-	twitchChannels = viper.GetStringSlice("TWITCH_CHANNELS")
-	settings.User.Name = viper.GetString("TWITCH_USER")
-	settings.UserToken = viper.GetBool("TWITCH_USER_TOKEN")
-	settings.ApiSettings = collectors.ApiSettings{
-		Options: helix.Options{
-			ClientID:        viper.GetString("TWITCH_CLIENT_ID"),
-			ClientSecret:    viper.GetString("TWITCH_CLIENT_SECRET"),
-			UserAccessToken: viper.GetString("TWITCH_ACCESS_TOKEN"),
-			RefreshToken:    viper.GetString("TWITCH_REFRESH_TOKEN"),
-			RedirectURI:     fmt.Sprint("http://" + viper.GetString("ADDRESS") + ":" + viper.GetString("LISTEN_PORT")),
-		},
-	}
+	config.RegisterFlags(rootCmd.Flags())
+	rootCmd.Flags().BoolVar(&printConfig, "print-config", false, "Print the effective configuration with the secrets redacted and exit")
 }
 
 func checkCoreSettings() error {
-	s := &settings
+	s := settings
 	if s.ApiSettings.Options.ClientID == "" {
 		return fmt.Errorf("Missing client ID")
 	}
@@ -135,25 +82,19 @@ func checkCoreSettings() error {
 	return nil
 }
 
-// Set The twitch settings.TwitchChannel struct and append
-// settings.user if not on the list, otherwise only user metrics will be collected
-func setChannelList(s *collectors.Settings) {
-	isInList := false
-	for _, c := range twitchChannels {
-		if s.User.Name == c {
-			isInList = true
-		}
-		s.Channels = append(s.Channels, collectors.TwitchChannel{Name: c})
-	}
+// Reloads the exporter settings on SIGHUP
+func reloadOnSignal(e *collectors.Exporter) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
 
-	if !isInList && s.User.Name != "" {
-		s.Channels = append(s.Channels, collectors.TwitchChannel{Name: s.User.Name})
+	for range hup {
+		e.Logger.Info("Received SIGHUP, reloading settings")
+		_ = e.Reload()
 	}
 }
 
-func exporter() {
-	s := &settings
-	setChannelList(s)
+func exporter(cmd *cobra.Command) {
+	s := settings
 
 	logger, err := slogLogger.NewLogger(s.LogLevel, s.LogFormat)
 	if err != nil {
@@ -171,6 +112,18 @@ func exporter() {
 		os.Exit(1)
 	}
 
+	// The configuration file and environment variables are read again, flags
+	// keep the values they were started with
+	exporter.LoadSettings = func() (*collectors.Settings, error) {
+		c, err := config.Resolve(cmd.Flags(), os.LookupEnv)
+		if err != nil {
+			return nil, err
+		}
+
+		return c.Settings(), nil
+	}
+	go reloadOnSignal(exporter)
+
 	srv := httpServer.NewServer(exporter)
 	logger.Info(fmt.Sprintf("Server ready and listening on port :%v", s.ListenPort))
 	log.Fatal(srv.ListenAndServe())
@@ -0,0 +1,297 @@
+package collectors
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	helix "github.com/nicklaw5/helix/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reports are daily, they are downloaded again after this interval
+const analyticsInterval = 6 * time.Hour
+
+// Layouts of the report dates
+var analyticsDateLayouts = []string{time.RFC3339, "2006-01-02", "01/02/2006", "2006-01-02 15:04:05"}
+
+func init() {
+	registerCollector(collectorInfo{
+		name:           "analytics",
+		scope:          userScope,
+		defaultEnabled: false,
+		scopes:         []string{"analytics:read:extensions", "analytics:read:games"},
+		factory:        newAnalyticsCollector,
+	})
+}
+
+// Latest day of an analytics report
+type analyticsReport struct {
+	date time.Time
+	// Numeric columns by metric name
+	values map[string]float64
+}
+
+// Reports of a user by report type and extension or game id
+type analyticsState struct {
+	refreshedAt time.Time
+	reports     map[string]map[string]analyticsReport
+}
+
+// Collects the latest daily values of the extension and game analytics
+// reports of the authenticated users
+type analyticsCollector struct {
+	e          *Exporter
+	value      *prometheus.Desc
+	reportDate *prometheus.Desc
+
+	mu     sync.Mutex
+	states map[string]*analyticsState
+}
+
+func newAnalyticsCollector(e *Exporter) collector {
+	return &analyticsCollector{
+		e: e,
+		value: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "analytics", "value"),
+			"Value of the analytics report column on the latest day of the report",
+			[]string{"name", "type", "id", "metric"}, nil,
+		),
+		reportDate: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "analytics", "report_date_timestamp_seconds"),
+			"Unix timestamp of the latest day of the analytics report",
+			[]string{"name", "type", "id"}, nil,
+		),
+		states: make(map[string]*analyticsState),
+	}
+}
+
+func (c *analyticsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.value
+	ch <- c.reportDate
+}
+
+func (c *analyticsCollector) Update(s *scrape, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for _, u := range c.e.sessionsFor("analytics") {
+		state, ok := c.states[u.name]
+		if !ok {
+			state = &analyticsState{}
+			c.states[u.name] = state
+		}
+
+		if time.Since(state.refreshedAt) >= analyticsInterval {
+			reports, err := c.e.analyticsReports(u)
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				state.reports = reports
+				state.refreshedAt = time.Now()
+			}
+		}
+
+		for reportType, reports := range state.reports {
+			for id, report := range reports {
+				ch <- prometheus.MustNewConstMetric(c.reportDate, prometheus.GaugeValue, float64(report.date.Unix()), u.name, reportType, id)
+				for metric, value := range report.values {
+					ch <- prometheus.MustNewConstMetric(c.value, prometheus.GaugeValue, value, u.name, reportType, id, metric)
+				}
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Returns the latest day of the extension and game reports of the user, by
+// report type and extension or game id
+func (e *Exporter) analyticsReports(u *userSession) (map[string]map[string]analyticsReport, error) {
+	urls := map[string]map[string]string{"extension": {}, "game": {}}
+
+	e.Logger.Debug("getting extension analytics", "user", u.name)
+	extensionParams := &helix.ExtensionAnalyticsParams{First: 100}
+	for {
+		resp, err := u.client.GetExtensionAnalytics(extensionParams)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get %v extension analytics: %w", u.name, err)
+		}
+
+		if resp.StatusCode != 200 {
+			return nil, fmt.Errorf("Failed to get %v extension analytics, status code %v: %v", u.name, resp.StatusCode, resp.ErrorMessage)
+		}
+
+		for _, a := range resp.Data.ExtensionAnalytics {
+			urls["extension"][a.ExtensionID] = a.URL
+		}
+
+		if resp.Data.Pagination.Cursor == "" || len(resp.Data.ExtensionAnalytics) == 0 {
+			break
+		}
+		extensionParams.After = resp.Data.Pagination.Cursor
+	}
+
+	e.Logger.Debug("getting game analytics", "user", u.name)
+	gameParams := &helix.GameAnalyticsParams{First: 100}
+	for {
+		resp, err := u.client.GetGameAnalytics(gameParams)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get %v game analytics: %w", u.name, err)
+		}
+
+		if resp.StatusCode != 200 {
+			return nil, fmt.Errorf("Failed to get %v game analytics, status code %v: %v", u.name, resp.StatusCode, resp.ErrorMessage)
+		}
+
+		for _, a := range resp.Data.GameAnalytics {
+			urls["game"][a.GameID] = a.URL
+		}
+
+		if resp.Data.Pagination.Cursor == "" || len(resp.Data.GameAnalytics) == 0 {
+			break
+		}
+		gameParams.After = resp.Data.Pagination.Cursor
+	}
+
+	reports := make(map[string]map[string]analyticsReport)
+	for reportType, byID := range urls {
+		reports[reportType] = make(map[string]analyticsReport)
+		for id, url := range byID {
+			report, err := e.downloadAnalyticsReport(url)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to download %v %v analytics report %v: %w", u.name, reportType, id, err)
+			}
+
+			if report != nil {
+				reports[reportType][id] = *report
+			}
+		}
+	}
+
+	return reports, nil
+}
+
+// Downloads the report, the url is signed and needs no authorization
+func (e *Exporter) downloadAnalyticsReport(url string) (*analyticsReport, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := e.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status code %v", resp.StatusCode)
+	}
+
+	return parseAnalyticsReport(resp.Body)
+}
+
+// Parses a CSV analytics report, returning the numeric columns of the latest
+// day, nil when the report has no rows. Rows are assumed to be in date order
+// when the dates can't be parsed.
+func parseAnalyticsReport(r io.Reader) (*analyticsReport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read report header: %w", err)
+	}
+
+	dateColumn := -1
+	for i, column := range header {
+		if metricName(column) == "date" {
+			dateColumn = i
+		}
+	}
+
+	var latest *analyticsReport
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read report: %w", err)
+		}
+
+		report := analyticsReport{values: make(map[string]float64)}
+		if dateColumn >= 0 && dateColumn < len(row) {
+			report.date = parseAnalyticsDate(row[dateColumn])
+		}
+
+		if latest != nil && report.date.Before(latest.date) {
+			continue
+		}
+
+		for i, value := range row {
+			// Ids are numeric but not values
+			if i == dateColumn || i >= len(header) || isIDColumn(header[i]) {
+				continue
+			}
+
+			v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+
+			report.values[metricName(header[i])] = v
+		}
+
+		latest = &report
+	}
+
+	return latest, nil
+}
+
+func isIDColumn(column string) bool {
+	name := metricName(column)
+	return name == "id" || strings.HasSuffix(name, "_id")
+}
+
+func parseAnalyticsDate(value string) time.Time {
+	for _, layout := range analyticsDateLayouts {
+		if date, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return date
+		}
+	}
+
+	return time.Time{}
+}
+
+// Returns the column name in snake case, "Extension Details Page Visits"
+// becomes extension_details_page_visits
+func metricName(column string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.TrimSpace(column) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if underscore && b.Len() > 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			underscore = false
+		} else {
+			underscore = true
+		}
+	}
+
+	return b.String()
+}
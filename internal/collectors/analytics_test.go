@@ -0,0 +1,132 @@
+package collectors
+
+import (
+	"maps"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAnalyticsReport(t *testing.T) {
+	tests := []struct {
+		name           string
+		file           string
+		data           string
+		expectedDate   time.Time
+		expectedValues map[string]float64
+		expectedNil    bool
+		expectedErr    bool
+	}{
+		{
+			name:         "Extension report",
+			file:         "testdata/extension_analytics.csv",
+			expectedDate: time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC),
+			expectedValues: map[string]float64{
+				"extension_details_page_visits": 98,
+				"installs":                      11,
+				"uninstalls":                    3,
+				"activations":                   7,
+				"unique_active_channels":        325,
+				"renders":                       47210,
+				"unique_renders":                12500,
+				"views":                         31000,
+				"unique_viewers":                9400,
+				"clicks":                        1621,
+				"interaction_rate":              0.1298,
+			},
+		},
+		{
+			name:         "Game report",
+			file:         "testdata/game_analytics.csv",
+			expectedDate: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+			expectedValues: map[string]float64{
+				"live_views":          1350,
+				"unique_live_viewers": 342,
+				"hours_watched":       95,
+				"broadcasters":        14,
+				"hours_broadcast":     44,
+				"clips_created":       9,
+				"clip_views":          120,
+			},
+		},
+		{
+			name:        "Empty report",
+			data:        "",
+			expectedNil: true,
+		},
+		{
+			name:        "Header only",
+			data:        "Date,Installs\n",
+			expectedNil: true,
+		},
+		{
+			name:        "Malformed report",
+			data:        "Date,Installs\n\"2026-10-17,1\n",
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data
+			if tt.file != "" {
+				b, err := os.ReadFile(tt.file)
+				if err != nil {
+					t.Fatalf("failed to read fixture: %v", err)
+				}
+				data = string(b)
+			}
+
+			report, err := parseAnalyticsReport(strings.NewReader(data))
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error: %v, got: %v", tt.expectedErr, err)
+			}
+
+			if tt.expectedErr || tt.expectedNil {
+				if report != nil {
+					t.Errorf("expected no report, got %v", report)
+				}
+				return
+			}
+
+			if !report.date.Equal(tt.expectedDate) {
+				t.Errorf("expected date %v, got %v", tt.expectedDate, report.date)
+			}
+
+			if !maps.Equal(report.values, tt.expectedValues) {
+				t.Errorf("expected values %v, got %v", tt.expectedValues, report.values)
+			}
+		})
+	}
+}
+
+func TestAnalyticsCollector(t *testing.T) {
+	extensionReport, _ := os.ReadFile("testdata/extension_analytics.csv")
+	e, fake := newTestExporter(t, map[string][]fakeResponse{
+		"/helix/analytics/extensions": {{200, `{"data":[{"extension_id":"abc123def456","URL":"https://reports.twitch.tv/extension.csv"}]}`}},
+		"/helix/analytics/games":      {{200, `{"data":[]}`}},
+		"/extension.csv":              {{200, string(extensionReport)}},
+	}, &Settings{
+		UserToken:  true,
+		Users:      []TwitchUser{{Name: "cool4pso", AccessToken: "token"}},
+		Collectors: map[string]bool{"analytics": true},
+	})
+
+	c := e.collectors["analytics"]
+	for range 2 {
+		metrics, err := updateMetrics(t, c, &scrape{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if got := metrics["twitch_analytics_value{abc123def456,installs,cool4pso,extension}"]; got != 11 {
+			t.Errorf("expected 11 installs, got %v", metrics)
+		}
+	}
+
+	// The report is only downloaded again after the analytics interval
+	if calls := fake.callCount("/extension.csv"); calls != 1 {
+		t.Errorf("expected 1 report download, got %v", calls)
+	}
+}
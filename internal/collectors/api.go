@@ -0,0 +1,80 @@
+package collectors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	helix "github.com/nicklaw5/helix/v2"
+)
+
+// Response of the endpoints requested without the helix client
+type apiResponse[T any] struct {
+	Data       []T              `json:"data"`
+	Pagination helix.Pagination `json:"pagination"`
+	Message    string           `json:"message"`
+}
+
+// Requests an api endpoint for the endpoints the helix client does not
+// support or does not decode every field of, following the pagination until
+// the last page. Like the helix client, the user token of the client is used
+// when it has one, the app token otherwise.
+func getAPI[T any](e *Exporter, client *helix.Client, path string, query url.Values) ([]T, error) {
+	token := client.GetUserAccessToken()
+	if token == "" {
+		token = client.GetAppAccessToken()
+	}
+
+	httpClient := e.httpClient()
+	var data []T
+	for {
+		req, err := http.NewRequest(http.MethodGet, helix.DefaultAPIBaseURL+path+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Client-ID", e.Settings.ApiSettings.Options.ClientID)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		body, err := doAPI[T](httpClient, req)
+		if err != nil {
+			return nil, err
+		}
+
+		data = append(data, body.Data...)
+		if body.Pagination.Cursor == "" || len(body.Data) == 0 {
+			return data, nil
+		}
+		query.Set("after", body.Pagination.Cursor)
+	}
+}
+
+// Returns the http client of the api settings
+func (e *Exporter) httpClient() helix.HTTPClient {
+	if e.Settings.ApiSettings.Options.HTTPClient != nil {
+		return e.Settings.ApiSettings.Options.HTTPClient
+	}
+
+	return http.DefaultClient
+}
+
+func doAPI[T any](client helix.HTTPClient, req *http.Request) (*apiResponse[T], error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body apiResponse[T]
+	decodeErr := json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status code %v: %v", resp.StatusCode, body.Message)
+	}
+
+	if decodeErr != nil {
+		return nil, fmt.Errorf("Failed to decode response: %w", decodeErr)
+	}
+
+	return &body, nil
+}
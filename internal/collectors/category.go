@@ -0,0 +1,379 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	helix "github.com/nicklaw5/helix/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// Streams counted for the top streams viewer share when the number is not set
+	DefaultCategoryTopStreams = 10
+	// Top games searched for the rank of the categories when the number is not set
+	DefaultCategoryTopGames = 100
+	// Used when the categories interval is not set
+	DefaultCategoryInterval = 5 * time.Minute
+)
+
+func init() {
+	registerCollector(collectorInfo{
+		name:           "category",
+		scope:          globalScope,
+		defaultEnabled: true,
+		factory:        newCategoryCollector,
+	})
+}
+
+// Category is a game followed by the category collector, by name or id
+type Category struct {
+	Name string
+	ID   string
+}
+
+// Categories configures the category collector
+type Categories struct {
+	Games []Category
+	// Number of streams whose share of the category viewers is exported
+	TopStreams int
+	// Number of top games searched for the rank of the categories
+	TopGames int
+	// Time between reads of the category streams and top games, scrapes in
+	// between export the last aggregates
+	Interval time.Duration
+}
+
+func (c *Categories) interval() time.Duration {
+	if c == nil || c.Interval <= 0 {
+		return DefaultCategoryInterval
+	}
+
+	return c.Interval
+}
+
+// Live streams aggregates of a game, refreshed every categories interval
+type categoryState struct {
+	refreshedAt time.Time
+	viewers     int
+	streams     int
+	// Viewers of the top streams, top is the number of streams counted
+	top        int
+	topViewers int
+	languages  map[string]int
+}
+
+// Ranks of the games within the top games, refreshed every categories interval
+type topGamesState struct {
+	refreshedAt time.Time
+	topGames    int
+	ranks       map[string]int
+}
+
+// Collects the live streams aggregates of the configured categories and
+// their rank among the top games
+type categoryCollector struct {
+	e               *Exporter
+	viewers         *prometheus.Desc
+	streams         *prometheus.Desc
+	topStreamsShare *prometheus.Desc
+	languageViewers *prometheus.Desc
+	topGamesRank    *prometheus.Desc
+
+	// Games resolved from the configured names and ids, they don't change
+	mu    sync.Mutex
+	games map[Category]helix.Game
+
+	// Aggregates by game id, only read and written by the collector
+	statesMu sync.Mutex
+	states   map[string]*categoryState
+	ranks    topGamesState
+}
+
+func newCategoryCollector(e *Exporter) collector {
+	return &categoryCollector{
+		e: e,
+		viewers: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "category", "viewers_total"),
+			"Current viewers of the live streams of the category",
+			[]string{"game"}, nil,
+		),
+		streams: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "category", "streams_total"),
+			"Number of live streams of the category",
+			[]string{"game"}, nil,
+		),
+		topStreamsShare: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "category", "top_streams_viewer_share"),
+			"Share of the category viewers watching the top streams",
+			[]string{"game", "top"}, nil,
+		),
+		languageViewers: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "category", "language_viewers_total"),
+			"Current viewers of the live streams of the category by stream language",
+			[]string{"game", "language"}, nil,
+		),
+		topGamesRank: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "top_games_rank"),
+			"Rank of the category among the games with the most viewers",
+			[]string{"game"}, nil,
+		),
+		games:  make(map[Category]helix.Game),
+		states: make(map[string]*categoryState),
+	}
+}
+
+func (c *categoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.viewers
+	ch <- c.streams
+	ch <- c.topStreamsShare
+	ch <- c.languageViewers
+	ch <- c.topGamesRank
+}
+
+func (c *categoryCollector) Update(s *scrape, ch chan<- prometheus.Metric) error {
+	c.e.configMu.RLock()
+	categories := c.e.Settings.Categories
+	c.e.configMu.RUnlock()
+
+	if categories == nil || len(categories.Games) == 0 {
+		return nil
+	}
+
+	var errs []error
+	games, resolved, err := c.resolveGames(categories.Games)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	topStreams := categories.TopStreams
+	if topStreams <= 0 {
+		topStreams = DefaultCategoryTopStreams
+	}
+
+	c.statesMu.Lock()
+	defer c.statesMu.Unlock()
+
+	// Games no longer configured don't keep their aggregates, games that
+	// could not be resolved this time may still be configured
+	if resolved {
+		for id := range c.states {
+			if !slices.ContainsFunc(games, func(g helix.Game) bool { return g.ID == id }) {
+				delete(c.states, id)
+			}
+		}
+	}
+
+	for _, game := range games {
+		state, ok := c.states[game.ID]
+		if !ok {
+			state = &categoryState{}
+			c.states[game.ID] = state
+		}
+
+		if time.Since(state.refreshedAt) >= categories.interval() || state.top != topStreams {
+			if err := c.e.refreshCategory(game, state, topStreams); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if state.refreshedAt.IsZero() {
+			continue
+		}
+
+		share := 0.0
+		if state.viewers > 0 {
+			share = float64(state.topViewers) / float64(state.viewers)
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.viewers, prometheus.GaugeValue, float64(state.viewers), game.Name)
+		ch <- prometheus.MustNewConstMetric(c.streams, prometheus.GaugeValue, float64(state.streams), game.Name)
+		ch <- prometheus.MustNewConstMetric(c.topStreamsShare, prometheus.GaugeValue, share, game.Name, fmt.Sprint(state.top))
+		for language, count := range state.languages {
+			ch <- prometheus.MustNewConstMetric(c.languageViewers, prometheus.GaugeValue, float64(count), game.Name, language)
+		}
+	}
+
+	if resolved && (time.Since(c.ranks.refreshedAt) >= categories.interval() || c.ranks.topGames != categories.TopGames) {
+		ranks, err := c.e.topGamesRanks(games, categories.TopGames)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			c.ranks = topGamesState{refreshedAt: time.Now(), topGames: categories.TopGames, ranks: ranks}
+		}
+	}
+
+	for _, game := range games {
+		if rank, ok := c.ranks.ranks[game.ID]; ok {
+			ch <- prometheus.MustNewConstMetric(c.topGamesRank, prometheus.GaugeValue, float64(rank), game.Name)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Reads the live streams of the game into the state. The state is only
+// changed when every page is read.
+func (e *Exporter) refreshCategory(game helix.Game, state *categoryState, topStreams int) error {
+	streams, err := e.categoryStreams(game)
+	if err != nil {
+		return err
+	}
+
+	next := categoryState{refreshedAt: time.Now(), streams: len(streams), top: topStreams, languages: make(map[string]int)}
+	for i, stream := range streams {
+		next.viewers += stream.ViewerCount
+		next.languages[stream.Language] += stream.ViewerCount
+		// Streams are sorted by viewer count
+		if i < topStreams {
+			next.topViewers += stream.ViewerCount
+		}
+	}
+
+	*state = next
+	return nil
+}
+
+// Returns the games of the categories, asking the api only for the
+// categories not resolved before. When the api request fails the games
+// resolved before are still returned and resolved is false.
+func (c *categoryCollector) resolveGames(categories []Category) (games []helix.Game, resolved bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	params := &helix.GamesParams{}
+	for _, category := range categories {
+		if _, ok := c.games[category]; ok {
+			continue
+		}
+
+		if category.ID != "" {
+			params.IDs = append(params.IDs, category.ID)
+		} else {
+			params.Names = append(params.Names, category.Name)
+		}
+	}
+
+	var errs []error
+	resolved = true
+	if len(params.IDs) > 0 || len(params.Names) > 0 {
+		if err := c.requestGames(categories, params); err != nil {
+			errs = append(errs, err)
+			resolved = false
+		}
+	}
+
+	for _, category := range categories {
+		game, ok := c.games[category]
+		if !ok {
+			if resolved {
+				errs = append(errs, fmt.Errorf("Could not find game %v", category.Name+category.ID))
+			}
+			continue
+		}
+
+		// The same game can be configured by name and id
+		if slices.ContainsFunc(games, func(g helix.Game) bool { return g.ID == game.ID }) {
+			continue
+		}
+
+		games = append(games, game)
+	}
+
+	return games, resolved, errors.Join(errs...)
+}
+
+// Resolves the games of the params, adding them to the resolved games
+func (c *categoryCollector) requestGames(categories []Category, params *helix.GamesParams) error {
+	c.e.Logger.Debug("getting games", "ids", params.IDs, "names", params.Names)
+	resp, err := c.e.client.GetGames(params)
+	if err != nil {
+		return fmt.Errorf("Failed to get games: %w", err)
+	}
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Failed to get games, status code %v: %v", resp.StatusCode, resp.ErrorMessage)
+	}
+
+	for _, category := range categories {
+		for _, game := range resp.Data.Games {
+			if game.ID == category.ID || (category.ID == "" && strings.EqualFold(game.Name, category.Name)) {
+				c.games[category] = game
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns every live stream of the game, sorted by viewer count
+func (e *Exporter) categoryStreams(game helix.Game) ([]helix.Stream, error) {
+	e.Logger.Debug("getting category streams", "game", game.Name)
+	var streams []helix.Stream
+	params := &helix.StreamsParams{GameIDs: []string{game.ID}, Type: "live", First: 100}
+	for {
+		resp, err := e.client.GetStreams(params)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get game %v streams: %w", game.Name, err)
+		}
+
+		if resp.StatusCode != 200 {
+			return nil, fmt.Errorf("Failed to get game %v streams, status code %v: %v", game.Name, resp.StatusCode, resp.ErrorMessage)
+		}
+
+		streams = append(streams, resp.Data.Streams...)
+		if resp.Data.Pagination.Cursor == "" || len(resp.Data.Streams) == 0 {
+			return streams, nil
+		}
+		params.After = resp.Data.Pagination.Cursor
+	}
+}
+
+// Returns the rank of the games found within the top games, by game id
+func (e *Exporter) topGamesRanks(games []helix.Game, topGames int) (map[string]int, error) {
+	if topGames <= 0 {
+		topGames = DefaultCategoryTopGames
+	}
+
+	wanted := make(map[string]bool)
+	for _, game := range games {
+		wanted[game.ID] = true
+	}
+
+	e.Logger.Debug("getting top games", "top", topGames)
+	ranks := make(map[string]int)
+	rank := 0
+	params := &helix.TopGamesParams{First: min(topGames, 100)}
+	for rank < topGames && len(ranks) < len(wanted) {
+		resp, err := e.client.GetTopGames(params)
+		if err != nil {
+			return ranks, fmt.Errorf("Failed to get top games: %w", err)
+		}
+
+		if resp.StatusCode != 200 {
+			return ranks, fmt.Errorf("Failed to get top games, status code %v: %v", resp.StatusCode, resp.ErrorMessage)
+		}
+
+		for _, game := range resp.Data.Games {
+			rank++
+			if rank > topGames {
+				break
+			}
+
+			if wanted[game.ID] {
+				ranks[game.ID] = rank
+			}
+		}
+
+		if resp.Data.Pagination.Cursor == "" || len(resp.Data.Games) == 0 {
+			break
+		}
+		params.After = resp.Data.Pagination.Cursor
+	}
+
+	return ranks, nil
+}
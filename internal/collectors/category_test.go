@@ -0,0 +1,231 @@
+package collectors
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	gamesPath    = "/helix/games"
+	topGamesPath = "/helix/games/top"
+)
+
+// Runs the collector update, returning the metric values by name and label
+// values sorted by label name, and the update error
+func updateMetrics(t *testing.T, c collector, s *scrape) (map[string]float64, error) {
+	t.Helper()
+
+	ch := make(chan prometheus.Metric, 1000)
+	err := c.Update(s, ch)
+	close(ch)
+
+	metrics := make(map[string]float64)
+	for m := range ch {
+		var metric dto.Metric
+		if err := m.Write(&metric); err != nil {
+			t.Fatalf("failed to write metric: %v", err)
+		}
+
+		var labels []string
+		for _, l := range metric.GetLabel() {
+			labels = append(labels, l.GetValue())
+		}
+
+		// The fully qualified name is the first quoted value of the description
+		name := strings.Split(m.Desc().String(), `"`)[1]
+		key := name + "{" + strings.Join(labels, ",") + "}"
+		switch {
+		case metric.Gauge != nil:
+			metrics[key] = metric.GetGauge().GetValue()
+		case metric.Counter != nil:
+			metrics[key] = metric.GetCounter().GetValue()
+		}
+	}
+
+	return metrics, err
+}
+
+func TestCategoryCollector(t *testing.T) {
+	tests := []struct {
+		name            string
+		responses       map[string][]fakeResponse
+		expectedErr     bool
+		expectedMetrics map[string]float64
+	}{
+		{
+			name: "Category aggregates",
+			responses: map[string][]fakeResponse{
+				gamesPath: {{200, `{"data":[{"id":"1","name":"Just Chatting"},{"id":"2","name":"Minecraft"}]}`}},
+				streamsPath: {
+					{200, `{"data":[{"viewer_count":60,"language":"en"},{"viewer_count":20,"language":"pt"}],"pagination":{"cursor":"next"}}`},
+					{200, `{"data":[{"viewer_count":20,"language":"en"}]}`},
+					{200, `{"data":[]}`},
+				},
+				topGamesPath: {
+					{200, `{"data":[{"id":"3","name":"Fortnite"},{"id":"1","name":"Just Chatting"}],"pagination":{"cursor":"next"}}`},
+					{200, `{"data":[{"id":"4","name":"Dota 2"}]}`},
+				},
+			},
+			expectedMetrics: map[string]float64{
+				"twitch_category_viewers_total{Just Chatting}":              100,
+				"twitch_category_streams_total{Just Chatting}":              3,
+				"twitch_category_top_streams_viewer_share{Just Chatting,1}": 0.6,
+				"twitch_category_language_viewers_total{Just Chatting,en}":  80,
+				"twitch_category_language_viewers_total{Just Chatting,pt}":  20,
+				"twitch_category_viewers_total{Minecraft}":                  0,
+				"twitch_category_streams_total{Minecraft}":                  0,
+				"twitch_top_games_rank{Just Chatting}":                      2,
+				"twitch_category_top_streams_viewer_share{Minecraft,1}":     0,
+			},
+		},
+		{
+			name: "Unknown game",
+			responses: map[string][]fakeResponse{
+				gamesPath:    {{200, `{"data":[{"id":"1","name":"Just Chatting"}]}`}},
+				streamsPath:  {{200, `{"data":[{"viewer_count":10,"language":"en"}]}`}},
+				topGamesPath: {{200, `{"data":[]}`}},
+			},
+			expectedErr: true,
+			expectedMetrics: map[string]float64{
+				"twitch_category_viewers_total{Just Chatting}": 10,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestExporter(t, tt.responses, &Settings{
+				Categories: &Categories{
+					Games:      []Category{{Name: "just chatting"}, {ID: "2"}},
+					TopStreams: 1,
+				},
+			})
+
+			metrics, err := updateMetrics(t, e.collectors["category"], &scrape{})
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error: %v, got: %v", tt.expectedErr, err)
+			}
+
+			for name, value := range tt.expectedMetrics {
+				if got, ok := metrics[name]; !ok || got != value {
+					t.Errorf("expected %v %v, got %v", name, value, metrics)
+				}
+			}
+		})
+	}
+}
+
+func TestCategoryCollectorInterval(t *testing.T) {
+	e, fake := newTestExporter(t, map[string][]fakeResponse{
+		gamesPath: {{200, `{"data":[{"id":"1","name":"Just Chatting"}]}`}},
+		streamsPath: {
+			{200, `{"data":[{"viewer_count":10,"language":"en"}]}`},
+			{200, `{"data":[{"viewer_count":30,"language":"en"}]}`},
+		},
+		topGamesPath: {{200, `{"data":[{"id":"1","name":"Just Chatting"}]}`}},
+	}, &Settings{
+		Categories: &Categories{
+			Games:    []Category{{ID: "1"}},
+			Interval: time.Hour,
+		},
+	})
+	c := e.collectors["category"].(*categoryCollector)
+
+	tests := []struct {
+		name            string
+		expire          bool
+		expectedViewers float64
+		expectedCalls   int
+	}{
+		{
+			name:            "First scrape reads the streams",
+			expectedViewers: 10,
+			expectedCalls:   1,
+		},
+		{
+			name:            "Scrapes within the interval export the last aggregates",
+			expectedViewers: 10,
+			expectedCalls:   1,
+		},
+		{
+			name:            "Scrapes after the interval read the streams again",
+			expire:          true,
+			expectedViewers: 30,
+			expectedCalls:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.expire {
+				c.states["1"].refreshedAt = time.Now().Add(-time.Hour)
+			}
+
+			metrics, err := updateMetrics(t, c, &scrape{})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if got := metrics["twitch_category_viewers_total{Just Chatting}"]; got != tt.expectedViewers {
+				t.Errorf("expected %v viewers, got %v", tt.expectedViewers, metrics)
+			}
+
+			if got := metrics["twitch_top_games_rank{Just Chatting}"]; got != 1 {
+				t.Errorf("expected rank 1, got %v", metrics)
+			}
+
+			if calls := fake.callCount(streamsPath); calls != tt.expectedCalls {
+				t.Errorf("expected %v streams requests, got %v", tt.expectedCalls, calls)
+			}
+		})
+	}
+
+	if calls := fake.callCount(topGamesPath); calls != 1 {
+		t.Errorf("expected 1 top games request, got %v", calls)
+	}
+}
+
+func TestCategoryCollectorGamesFailure(t *testing.T) {
+	e, fake := newTestExporter(t, map[string][]fakeResponse{
+		gamesPath: {
+			{200, `{"data":[{"id":"1","name":"Just Chatting"}]}`},
+			{500, `{"message":"internal error"}`},
+		},
+		streamsPath:  {{200, `{"data":[{"viewer_count":10,"language":"en"}]}`}},
+		topGamesPath: {{200, `{"data":[{"id":"1","name":"Just Chatting"}]}`}},
+	}, &Settings{
+		Categories: &Categories{Games: []Category{{ID: "1"}}, Interval: time.Hour},
+	})
+	c := e.collectors["category"].(*categoryCollector)
+
+	if _, err := updateMetrics(t, c, &scrape{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// A new game can't be resolved once the ranks are due for a refresh
+	e.Settings.Categories.Games = append(e.Settings.Categories.Games, Category{Name: "Minecraft"})
+	c.ranks.refreshedAt = time.Now().Add(-time.Hour)
+
+	metrics, err := updateMetrics(t, c, &scrape{})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	expectedMetrics := map[string]float64{
+		"twitch_category_viewers_total{Just Chatting}": 10,
+		"twitch_top_games_rank{Just Chatting}":         1,
+	}
+	for name, value := range expectedMetrics {
+		if got, ok := metrics[name]; !ok || got != value {
+			t.Errorf("expected %v %v, got %v", name, value, metrics)
+		}
+	}
+
+	if calls := fake.callCount(topGamesPath); calls != 1 {
+		t.Errorf("expected 1 top games request, got %v", calls)
+	}
+}
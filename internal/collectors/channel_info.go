@@ -0,0 +1,214 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	helix "github.com/nicklaw5/helix/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Labels of the channel info metric
+var channelInfoLabels = []string{"broadcaster_type", "language", "game_name", "content_classification_labels", "is_branded_content"}
+
+// Channels requested at once, the limit of the users and channels endpoints
+const channelBatchSize = 100
+
+func init() {
+	registerCollector(collectorInfo{
+		name:           "channel",
+		scope:          channelScope,
+		defaultEnabled: true,
+		labels:         channelInfoLabels,
+		factory:        newChannelInfoCollector,
+	})
+}
+
+// Channel information, requested without the helix client which does not
+// decode the content classification labels and branded content
+type channelInformation struct {
+	BroadcasterID               string   `json:"broadcaster_id"`
+	BroadcasterLanguage         string   `json:"broadcaster_language"`
+	GameName                    string   `json:"game_name"`
+	Title                       string   `json:"title"`
+	ContentClassificationLabels []string `json:"content_classification_labels"`
+	IsBrandedContent            bool     `json:"is_branded_content"`
+}
+
+// Collects the channel information, account creation and title changes
+type channelInfoCollector struct {
+	e            *Exporter
+	info         *channelDesc
+	createdAt    *channelDesc
+	titleChanges *channelDesc
+
+	mu           sync.Mutex
+	titles       map[string]string
+	titleChanged map[string]int
+}
+
+func newChannelInfoCollector(e *Exporter) collector {
+	return &channelInfoCollector{
+		e: e,
+		info: newChannelDesc(
+			prometheus.BuildFQName(namespace, "channel", "info"),
+			"Channel information",
+			channelInfoLabels...,
+		),
+		createdAt: newChannelDesc(
+			prometheus.BuildFQName(namespace, "channel", "created_timestamp_seconds"),
+			"Unix timestamp at which the channel account was created",
+		),
+		titleChanges: newChannelDesc(
+			prometheus.BuildFQName(namespace, "channel", "title_changes_total"),
+			"Number of stream title changes seen by the exporter",
+		),
+		titles:       make(map[string]string),
+		titleChanged: make(map[string]int),
+	}
+}
+
+func (c *channelInfoCollector) Describe(ch chan<- *prometheus.Desc) {
+	labelNames := c.e.currentLabelNames()
+	ch <- c.info.desc(labelNames)
+	ch <- c.createdAt.desc(labelNames)
+	ch <- c.titleChanges.desc(labelNames)
+}
+
+func (c *channelInfoCollector) Update(s *scrape, ch chan<- prometheus.Metric) error {
+	channels := s.channelsFor("channel")
+
+	var errs []error
+	for start := 0; start < len(channels); start += channelBatchSize {
+		batch := channels[start:min(start+channelBatchSize, len(channels))]
+		if err := c.updateBatch(s, batch, ch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *channelInfoCollector) updateBatch(s *scrape, channels []*TwitchChannel, ch chan<- prometheus.Metric) error {
+	var logins []string
+	for _, twitchChannel := range channels {
+		logins = append(logins, twitchChannel.Name)
+	}
+
+	users, err := c.e.channelUsers(logins)
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+
+	info, err := c.e.channelInformation(ids)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, twitchChannel := range channels {
+		u, ok := users[strings.ToLower(twitchChannel.Name)]
+		if !ok {
+			errs = append(errs, fmt.Errorf("Could not find channel %v", twitchChannel.Name))
+			continue
+		}
+
+		s.channelMetric(ch, c.createdAt, prometheus.GaugeValue, float64(u.CreatedAt.Unix()), twitchChannel)
+
+		i, ok := info[u.ID]
+		if !ok {
+			errs = append(errs, fmt.Errorf("Could not find channel %v information", twitchChannel.Name))
+			continue
+		}
+
+		s.channelMetric(ch, c.info, prometheus.GaugeValue, 1, twitchChannel,
+			u.BroadcasterType,
+			i.BroadcasterLanguage,
+			i.GameName,
+			strings.Join(i.ContentClassificationLabels, ","),
+			strconv.FormatBool(i.IsBrandedContent),
+		)
+		if !s.probe {
+			s.channelMetric(ch, c.titleChanges, prometheus.CounterValue, float64(c.titleChange(twitchChannel.Name, i.Title)), twitchChannel)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *channelInfoCollector) pruneChannels(s *scrape) {
+	keep := s.channelNamesFor("channel")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name := range c.titles {
+		if !keep[name] {
+			delete(c.titles, name)
+			delete(c.titleChanged, name)
+		}
+	}
+}
+
+// Records the channel title, returning the number of changes. The first
+// title seen is not a change.
+func (c *channelInfoCollector) titleChange(name, title string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if previous, ok := c.titles[name]; ok && previous != title {
+		c.titleChanged[name]++
+	}
+	c.titles[name] = title
+
+	return c.titleChanged[name]
+}
+
+// Returns the users of the channels by login
+func (e *Exporter) channelUsers(logins []string) (map[string]helix.User, error) {
+	e.Logger.Debug("getting channel users", "channels", logins)
+	resp, err := e.client.GetUsers(&helix.UsersParams{Logins: logins})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get channel users: %w", err)
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Failed to get channel users, status code %v: %v", resp.StatusCode, resp.ErrorMessage)
+	}
+
+	users := make(map[string]helix.User)
+	for _, u := range resp.Data.Users {
+		users[strings.ToLower(u.Login)] = u
+	}
+
+	return users, nil
+}
+
+// Returns the information of the channels by broadcaster id
+func (e *Exporter) channelInformation(ids []string) (map[string]channelInformation, error) {
+	info := make(map[string]channelInformation)
+	if len(ids) == 0 {
+		return info, nil
+	}
+
+	e.Logger.Debug("getting channel information", "broadcasterIDs", ids)
+	channels, err := getAPI[channelInformation](e, e.client, "/channels", url.Values{"broadcaster_id": ids})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get channel information: %w", err)
+	}
+
+	for _, i := range channels {
+		info[i.BroadcasterID] = i
+	}
+
+	return info, nil
+}
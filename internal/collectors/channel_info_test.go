@@ -0,0 +1,40 @@
+package collectors
+
+import (
+	"testing"
+)
+
+func TestChannelInfoCollector(t *testing.T) {
+	users := fakeResponse{200, `{"data":[{"id":"1","login":"cool4pso","broadcaster_type":"affiliate","created_at":"2020-01-01T00:00:00Z"}]}`}
+	e, _ := newTestExporter(t, map[string][]fakeResponse{
+		usersPath: {users},
+		channelsPath: {
+			{200, `{"data":[{"broadcaster_id":"1","broadcaster_language":"en","game_name":"Minecraft","title":"first","content_classification_labels":["Gambling","ProfanityVulgarity"],"is_branded_content":true}]}`},
+			{200, `{"data":[{"broadcaster_id":"1","broadcaster_language":"en","game_name":"Minecraft","title":"second","content_classification_labels":["Gambling","ProfanityVulgarity"],"is_branded_content":true}]}`},
+		},
+	}, &Settings{})
+
+	s := &scrape{channels: []TwitchChannel{{Name: "cool4pso"}, {Name: "missing"}}}
+	c := e.collectors["channel"]
+
+	metrics, err := updateMetrics(t, c, s)
+	if err == nil {
+		t.Errorf("expected error for the missing channel, got nil")
+	}
+
+	expected := map[string]float64{
+		"twitch_channel_info{affiliate,Gambling,ProfanityVulgarity,Minecraft,true,en,cool4pso}": 1,
+		"twitch_channel_created_timestamp_seconds{cool4pso}":                                    1577836800,
+		"twitch_channel_title_changes_total{cool4pso}":                                          0,
+	}
+	for name, value := range expected {
+		if got, ok := metrics[name]; !ok || got != value {
+			t.Errorf("expected %v %v, got %v", name, value, metrics)
+		}
+	}
+
+	metrics, _ = updateMetrics(t, c, s)
+	if got := metrics["twitch_channel_title_changes_total{cool4pso}"]; got != 1 {
+		t.Errorf("expected 1 title change, got %v", got)
+	}
+}
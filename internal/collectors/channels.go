@@ -0,0 +1,35 @@
+package collectors
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// Cached api results of a channel, reused until the channel refresh interval passes
+type channelState struct {
+	refreshedAt time.Time
+	isLive      int
+	viewerCount int
+}
+
+// Returns true if the collector is enabled for the channel, all collectors are
+// enabled when the channel does not list any
+func (c *TwitchChannel) collectorEnabled(name string) bool {
+	return len(c.Collectors) == 0 || slices.Contains(c.Collectors, name)
+}
+
+// Returns the sorted union of the custom label names of all channels
+func channelLabelNames(channels []TwitchChannel) []string {
+	var names []string
+	for _, c := range channels {
+		for name := range c.Labels {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
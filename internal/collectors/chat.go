@@ -0,0 +1,181 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	helix "github.com/nicklaw5/helix/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector(collectorInfo{
+		name:           "chat",
+		scope:          channelScope,
+		defaultEnabled: false,
+		labels:         []string{"tier", "type"},
+		factory:        newChatCollector,
+	})
+}
+
+type emoteKind struct {
+	tier      string
+	emoteType string
+}
+
+// Collects the emotes and chat settings of the channels
+type chatCollector struct {
+	e                    *Exporter
+	emotes               *channelDesc
+	slowMode             *channelDesc
+	followerMode         *channelDesc
+	followerModeDuration *channelDesc
+	subscriberMode       *channelDesc
+	emoteMode            *channelDesc
+	uniqueChatMode       *channelDesc
+}
+
+func newChatCollector(e *Exporter) collector {
+	return &chatCollector{
+		e: e,
+		emotes: newChannelDesc(
+			prometheus.BuildFQName(namespace, "", "emotes"),
+			"Number of channel emotes by tier and type",
+			"tier", "type",
+		),
+		slowMode: newChannelDesc(
+			prometheus.BuildFQName(namespace, "chat", "slow_mode_seconds"),
+			"Seconds chatters wait between messages, 0 when slow mode is off",
+		),
+		followerMode: newChannelDesc(
+			prometheus.BuildFQName(namespace, "chat", "follower_mode"),
+			"If only followers can chat",
+		),
+		followerModeDuration: newChannelDesc(
+			prometheus.BuildFQName(namespace, "chat", "follower_mode_duration_seconds"),
+			"How long users must follow before they can chat in follower mode",
+		),
+		subscriberMode: newChannelDesc(
+			prometheus.BuildFQName(namespace, "chat", "subscriber_mode"),
+			"If only subscribers can chat",
+		),
+		emoteMode: newChannelDesc(
+			prometheus.BuildFQName(namespace, "chat", "emote_mode"),
+			"If chat messages can only contain emotes",
+		),
+		uniqueChatMode: newChannelDesc(
+			prometheus.BuildFQName(namespace, "chat", "unique_mode"),
+			"If chat messages must be unique",
+		),
+	}
+}
+
+func (c *chatCollector) Describe(ch chan<- *prometheus.Desc) {
+	labelNames := c.e.currentLabelNames()
+	ch <- c.emotes.desc(labelNames)
+	ch <- c.slowMode.desc(labelNames)
+	ch <- c.followerMode.desc(labelNames)
+	ch <- c.followerModeDuration.desc(labelNames)
+	ch <- c.subscriberMode.desc(labelNames)
+	ch <- c.emoteMode.desc(labelNames)
+	ch <- c.uniqueChatMode.desc(labelNames)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func (c *chatCollector) Update(s *scrape, ch chan<- prometheus.Metric) error {
+	channels := s.channelsFor("chat")
+	var names []string
+	for _, twitchChannel := range channels {
+		names = append(names, twitchChannel.Name)
+	}
+
+	var errs []error
+	ids, err := c.e.channelIDs(names, !s.probe)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, twitchChannel := range channels {
+		id, ok := ids[strings.ToLower(twitchChannel.Name)]
+		if !ok {
+			if err == nil {
+				errs = append(errs, fmt.Errorf("Could not find channel %v", twitchChannel.Name))
+			}
+			continue
+		}
+
+		if emotes, err := c.e.emoteCounts(twitchChannel.Name, id); err != nil {
+			errs = append(errs, err)
+		} else {
+			for kind, count := range emotes {
+				s.channelMetric(ch, c.emotes, prometheus.GaugeValue, float64(count), twitchChannel, kind.tier, kind.emoteType)
+			}
+		}
+
+		settings, err := c.e.chatSettings(twitchChannel.Name, id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		slowMode := 0
+		if settings.SlowMode {
+			slowMode = settings.SlowModeWaitTime
+		}
+
+		s.channelMetric(ch, c.slowMode, prometheus.GaugeValue, float64(slowMode), twitchChannel)
+		s.channelMetric(ch, c.followerMode, prometheus.GaugeValue, boolValue(settings.FollowerMode), twitchChannel)
+		s.channelMetric(ch, c.followerModeDuration, prometheus.GaugeValue, float64(settings.FollowerModeDuration*60), twitchChannel)
+		s.channelMetric(ch, c.subscriberMode, prometheus.GaugeValue, boolValue(settings.SubscriberMode), twitchChannel)
+		s.channelMetric(ch, c.emoteMode, prometheus.GaugeValue, boolValue(settings.EmoteMode), twitchChannel)
+		s.channelMetric(ch, c.uniqueChatMode, prometheus.GaugeValue, boolValue(settings.UniqueChatMode), twitchChannel)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Returns the number of channel emotes by tier and type
+func (e *Exporter) emoteCounts(name, id string) (map[emoteKind]int, error) {
+	e.Logger.Debug("getting channel emotes", "channelName", name)
+	resp, err := e.client.GetChannelEmotes(&helix.GetChannelEmotesParams{BroadcasterID: id})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get channel %v emotes: %w", name, err)
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Failed to get channel %v emotes, status code %v: %v", name, resp.StatusCode, resp.ErrorMessage)
+	}
+
+	counts := make(map[emoteKind]int)
+	for _, emote := range resp.Data.Emotes {
+		counts[emoteKind{tier: emote.Tier, emoteType: emote.EmoteType}]++
+	}
+
+	return counts, nil
+}
+
+func (e *Exporter) chatSettings(name, id string) (helix.ChatSettings, error) {
+	e.Logger.Debug("getting channel chat settings", "channelName", name)
+	resp, err := e.client.GetChatSettings(&helix.GetChatSettingsParams{BroadcasterID: id})
+	if err != nil {
+		return helix.ChatSettings{}, fmt.Errorf("Failed to get channel %v chat settings: %w", name, err)
+	}
+
+	if resp.StatusCode != 200 {
+		return helix.ChatSettings{}, fmt.Errorf("Failed to get channel %v chat settings, status code %v: %v", name, resp.StatusCode, resp.ErrorMessage)
+	}
+
+	if len(resp.Data.Settings) == 0 {
+		return helix.ChatSettings{}, fmt.Errorf("Failed to get channel %v chat settings: empty response", name)
+	}
+
+	return resp.Data.Settings[0], nil
+}
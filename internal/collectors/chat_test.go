@@ -0,0 +1,40 @@
+package collectors
+
+import (
+	"testing"
+)
+
+func TestChatCollector(t *testing.T) {
+	e, _ := newTestExporter(t, map[string][]fakeResponse{
+		usersPath: {{200, `{"data":[{"id":"1","login":"cool4pso"}]}`}},
+		"/helix/chat/emotes": {{200, `{"data":[
+			{"id":"1","tier":"1000","emote_type":"subscriptions"},
+			{"id":"2","tier":"1000","emote_type":"subscriptions"},
+			{"id":"3","tier":"2000","emote_type":"subscriptions"},
+			{"id":"4","tier":"","emote_type":"follower"}
+		]}`}},
+		"/helix/chat/settings": {{200, `{"data":[{"broadcaster_id":"1","slow_mode":true,"slow_mode_wait_time":30,"follower_mode":true,"follower_mode_duration":10,"subscriber_mode":false,"emote_mode":true,"unique_chat_mode":false}]}`}},
+	}, &Settings{})
+
+	metrics, err := updateMetrics(t, e.collectors["chat"], &scrape{channels: []TwitchChannel{{Name: "cool4pso"}}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := map[string]float64{
+		"twitch_emotes{cool4pso,1000,subscriptions}":           2,
+		"twitch_emotes{cool4pso,2000,subscriptions}":           1,
+		"twitch_emotes{cool4pso,,follower}":                    1,
+		"twitch_chat_slow_mode_seconds{cool4pso}":              30,
+		"twitch_chat_follower_mode{cool4pso}":                  1,
+		"twitch_chat_follower_mode_duration_seconds{cool4pso}": 600,
+		"twitch_chat_subscriber_mode{cool4pso}":                0,
+		"twitch_chat_emote_mode{cool4pso}":                     1,
+		"twitch_chat_unique_mode{cool4pso}":                    0,
+	}
+	for name, value := range expected {
+		if got, ok := metrics[name]; !ok || got != value {
+			t.Errorf("expected %v %v, got %v", name, value, metrics)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+
+	helix "github.com/nicklaw5/helix/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector(collectorInfo{
+		name:           "chatters",
+		scope:          userScope,
+		defaultEnabled: false,
+		scopes:         []string{"moderator:read:chatters"},
+		factory:        newChattersCollector,
+	})
+}
+
+// Collects the chatters of the authenticated users channels and how they
+// compare to the viewers
+type chattersCollector struct {
+	e                  *Exporter
+	chatters           *prometheus.Desc
+	viewerChatterRatio *prometheus.Desc
+}
+
+func newChattersCollector(e *Exporter) collector {
+	return &chattersCollector{
+		e: e,
+		chatters: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "chatters_total"),
+			"Number of users connected to the channel chat",
+			[]string{"name"}, nil,
+		),
+		viewerChatterRatio: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "viewer_chatter_ratio"),
+			"Channel viewers per chatter",
+			[]string{"name"}, nil,
+		),
+	}
+}
+
+func (c *chattersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.chatters
+	ch <- c.viewerChatterRatio
+}
+
+func (c *chattersCollector) Update(s *scrape, ch chan<- prometheus.Metric) error {
+	var errs []error
+	for _, u := range c.e.sessionsFor("chatters") {
+		userID, err := c.e.getUserID(u)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		chatters, err := c.e.chatterCount(u, userID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.chatters, prometheus.GaugeValue, float64(chatters), u.name)
+
+		stream, err := c.e.stream(u.name, userID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if chatters == 0 {
+			continue
+		}
+
+		viewers := 0
+		if stream != nil {
+			viewers = stream.ViewerCount
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.viewerChatterRatio, prometheus.GaugeValue, float64(viewers)/float64(chatters), u.name)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Returns the number of chatters. The total is reported with every page, a
+// single chatter is requested instead of walking the pages.
+func (e *Exporter) chatterCount(u *userSession, userID string) (int, error) {
+	e.Logger.Debug("getting chatters", "user", u.name)
+	resp, err := u.client.GetChannelChatChatters(&helix.GetChatChattersParams{
+		BroadcasterID: userID,
+		ModeratorID:   userID,
+		First:         "1",
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get %v chatters: %w", u.name, err)
+	}
+
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("Failed to get %v chatters, status code %v: %v", u.name, resp.StatusCode, resp.ErrorMessage)
+	}
+
+	return resp.Data.Total, nil
+}
@@ -0,0 +1,79 @@
+package collectors
+
+import (
+	"testing"
+)
+
+func TestChattersCollector(t *testing.T) {
+	tests := []struct {
+		name            string
+		chatters        string
+		streams         string
+		expectedMetrics map[string]float64
+		missingMetrics  []string
+	}{
+		{
+			name:     "Live",
+			chatters: `{"data":[{"user_id":"2"}],"total":20,"pagination":{"cursor":"next"}}`,
+			streams:  `{"data":[{"user_id":"1","type":"live","viewer_count":100}]}`,
+			expectedMetrics: map[string]float64{
+				"twitch_chatters_total{cool4pso}":       20,
+				"twitch_viewer_chatter_ratio{cool4pso}": 5,
+			},
+		},
+		{
+			name:     "Offline",
+			chatters: `{"data":[{"user_id":"2"}],"total":4}`,
+			streams:  `{"data":[]}`,
+			expectedMetrics: map[string]float64{
+				"twitch_chatters_total{cool4pso}":       4,
+				"twitch_viewer_chatter_ratio{cool4pso}": 0,
+			},
+		},
+		{
+			name:     "No chatters",
+			chatters: `{"data":[],"total":0}`,
+			streams:  `{"data":[{"user_id":"1","type":"live","viewer_count":100}]}`,
+			expectedMetrics: map[string]float64{
+				"twitch_chatters_total{cool4pso}": 0,
+			},
+			missingMetrics: []string{"twitch_viewer_chatter_ratio{cool4pso}"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, fake := newTestExporter(t, map[string][]fakeResponse{
+				usersPath:              {{200, `{"data":[{"id":"1","login":"cool4pso"}]}`}},
+				"/helix/chat/chatters": {{200, tt.chatters}},
+				streamsPath:            {{200, tt.streams}},
+			}, &Settings{
+				UserToken:  true,
+				Users:      []TwitchUser{{Name: "cool4pso", AccessToken: "token"}},
+				Collectors: map[string]bool{"chatters": true},
+			})
+
+			metrics, err := updateMetrics(t, e.collectors["chatters"], &scrape{})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			for name, value := range tt.expectedMetrics {
+				if got, ok := metrics[name]; !ok || got != value {
+					t.Errorf("expected %v %v, got %v", name, value, metrics)
+				}
+			}
+
+			for _, name := range tt.missingMetrics {
+				if _, ok := metrics[name]; ok {
+					t.Errorf("expected no %v metric", name)
+				}
+			}
+
+			// Only the first page is requested
+			if calls := fake.callCount("/helix/chat/chatters"); calls != 1 {
+				t.Errorf("expected 1 chatters request, got %v", calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	helix "github.com/nicklaw5/helix/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// Used when the content refresh interval is not set
+	DefaultContentInterval = 15 * time.Minute
+	// Used when the content lookback is not set
+	DefaultContentLookback = 7 * 24 * time.Hour
+)
+
+// Types of videos, exported even when a channel has none of them
+var videoTypes = []string{"archive", "highlight", "upload"}
+
+func init() {
+	registerCollector(collectorInfo{
+		name:           "content",
+		scope:          channelScope,
+		defaultEnabled: false,
+		labels:         []string{"type"},
+		factory:        newContentCollector,
+	})
+}
+
+// Content configures how often and how far back the clips and videos of the
+// channels are read
+type Content struct {
+	Interval time.Duration
+	Lookback time.Duration
+}
+
+func (c *Content) interval() time.Duration {
+	if c == nil || c.Interval <= 0 {
+		return DefaultContentInterval
+	}
+
+	return c.Interval
+}
+
+func (c *Content) lookback() time.Duration {
+	if c == nil || c.Lookback <= 0 {
+		return DefaultContentLookback
+	}
+
+	return c.Lookback
+}
+
+type videoStats struct {
+	count int
+	views int
+}
+
+// Clips and videos of a channel, refreshed every content interval
+type contentState struct {
+	refreshedAt time.Time
+	// Clips created after the cursor are new, the ones created at the cursor
+	// were already counted
+	clipCursor    time.Time
+	clipCursorIDs map[string]bool
+	clipsCreated  int
+	topClipViews  int
+	videos        map[string]videoStats
+}
+
+// Collects the clips and videos of the channels
+type contentCollector struct {
+	e            *Exporter
+	clipsCreated *channelDesc
+	topClipViews *channelDesc
+	videos       *channelDesc
+	videoViews   *channelDesc
+
+	mu     sync.Mutex
+	states map[string]*contentState
+}
+
+func newContentCollector(e *Exporter) collector {
+	return &contentCollector{
+		e: e,
+		clipsCreated: newChannelDesc(
+			prometheus.BuildFQName(namespace, "clips", "created_total"),
+			"Number of clips created since the exporter started",
+		),
+		topClipViews: newChannelDesc(
+			prometheus.BuildFQName(namespace, "clips", "top_views"),
+			"View count of the most viewed clip created within the lookback window",
+		),
+		videos: newChannelDesc(
+			prometheus.BuildFQName(namespace, "videos", "recent"),
+			"Number of videos created within the lookback window",
+			"type",
+		),
+		videoViews: newChannelDesc(
+			prometheus.BuildFQName(namespace, "videos", "recent_views"),
+			"View count of the videos created within the lookback window",
+			"type",
+		),
+		states: make(map[string]*contentState),
+	}
+}
+
+func (c *contentCollector) Describe(ch chan<- *prometheus.Desc) {
+	labelNames := c.e.currentLabelNames()
+	ch <- c.clipsCreated.desc(labelNames)
+	ch <- c.topClipViews.desc(labelNames)
+	ch <- c.videos.desc(labelNames)
+	ch <- c.videoViews.desc(labelNames)
+}
+
+func (c *contentCollector) Update(s *scrape, ch chan<- prometheus.Metric) error {
+	c.e.configMu.RLock()
+	settings := c.e.Settings.Content
+	c.e.configMu.RUnlock()
+
+	channels := s.channelsFor("content")
+	var names []string
+	for _, twitchChannel := range channels {
+		names = append(names, twitchChannel.Name)
+	}
+
+	var errs []error
+	ids, err := c.e.channelIDs(names, !s.probe)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// Channels are updated one at a time, the states are only read and
+	// written by the collector
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, twitchChannel := range channels {
+		id, ok := ids[strings.ToLower(twitchChannel.Name)]
+		if !ok {
+			if err == nil {
+				errs = append(errs, fmt.Errorf("Could not find channel %v", twitchChannel.Name))
+			}
+			continue
+		}
+
+		state, ok := c.states[twitchChannel.Name]
+		if !ok {
+			state = &contentState{}
+			c.states[twitchChannel.Name] = state
+		}
+
+		if time.Since(state.refreshedAt) >= settings.interval() {
+			if err := c.e.refreshContent(twitchChannel.Name, id, state, settings.lookback()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if state.refreshedAt.IsZero() {
+			continue
+		}
+
+		if !s.probe {
+			s.channelMetric(ch, c.clipsCreated, prometheus.CounterValue, float64(state.clipsCreated), twitchChannel)
+		}
+		s.channelMetric(ch, c.topClipViews, prometheus.GaugeValue, float64(state.topClipViews), twitchChannel)
+		for _, videoType := range videoTypes {
+			stats := state.videos[videoType]
+			s.channelMetric(ch, c.videos, prometheus.GaugeValue, float64(stats.count), twitchChannel, videoType)
+			s.channelMetric(ch, c.videoViews, prometheus.GaugeValue, float64(stats.views), twitchChannel, videoType)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *contentCollector) pruneChannels(s *scrape) {
+	keep := s.channelNamesFor("content")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name := range c.states {
+		if !keep[name] {
+			delete(c.states, name)
+		}
+	}
+}
+
+// Reads the new clips, the top clip and the videos of the channel into the
+// state. The state is only changed when every request succeeds.
+func (e *Exporter) refreshContent(name, id string, state *contentState, lookback time.Duration) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	since := now.Add(-lookback)
+
+	next := *state
+	if state.refreshedAt.IsZero() {
+		// Clips created before the exporter started are not counted
+		next.clipCursor = now
+		next.clipCursorIDs = nil
+	} else {
+		// The cursor is never older than the lookback window, a long
+		// failing channel does not read its whole history
+		cursor := state.clipCursor
+		if cursor.Before(since) {
+			cursor = since
+		}
+
+		clips, err := e.clips(name, id, cursor, now, 0)
+		if err != nil {
+			return err
+		}
+
+		next.clipCursorIDs = make(map[string]bool)
+		for clipID := range state.clipCursorIDs {
+			next.clipCursorIDs[clipID] = true
+		}
+
+		for _, clip := range clips {
+			createdAt, err := time.Parse(time.RFC3339, clip.CreatedAt)
+			if err != nil || createdAt.Before(cursor) {
+				continue
+			}
+
+			// Clips are sorted by views, not by time, the cursor can move
+			// before every clip is read. Clips counted before are the ones
+			// at the previous cursor.
+			if createdAt.Equal(state.clipCursor) && state.clipCursorIDs[clip.ID] {
+				continue
+			}
+
+			if createdAt.After(next.clipCursor) {
+				next.clipCursor = createdAt
+				next.clipCursorIDs = make(map[string]bool)
+			}
+			if createdAt.Equal(next.clipCursor) {
+				next.clipCursorIDs[clip.ID] = true
+			}
+			next.clipsCreated++
+		}
+	}
+
+	top, err := e.clips(name, id, since, now, 1)
+	if err != nil {
+		return err
+	}
+
+	next.topClipViews = 0
+	if len(top) > 0 {
+		next.topClipViews = top[0].ViewCount
+	}
+
+	next.videos, err = e.videoStats(name, id, since)
+	if err != nil {
+		return err
+	}
+
+	next.refreshedAt = time.Now()
+	*state = next
+
+	return nil
+}
+
+// Returns the clips of the channel created between start and end, sorted by
+// view count. Every page is read when limit is 0.
+func (e *Exporter) clips(name, id string, start, end time.Time, limit int) ([]helix.Clip, error) {
+	e.Logger.Debug("getting channel clips", "channelName", name, "start", start, "end", end)
+	params := &helix.ClipsParams{
+		BroadcasterID: id,
+		StartedAt:     helix.Time{Time: start},
+		EndedAt:       helix.Time{Time: end},
+		First:         100,
+	}
+	if limit > 0 {
+		params.First = limit
+	}
+
+	var clips []helix.Clip
+	for {
+		resp, err := e.client.GetClips(params)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get channel %v clips: %w", name, err)
+		}
+
+		if resp.StatusCode != 200 {
+			return nil, fmt.Errorf("Failed to get channel %v clips, status code %v: %v", name, resp.StatusCode, resp.ErrorMessage)
+		}
+
+		clips = append(clips, resp.Data.Clips...)
+		if limit > 0 || resp.Data.Pagination.Cursor == "" || len(resp.Data.Clips) == 0 {
+			return clips, nil
+		}
+		params.After = resp.Data.Pagination.Cursor
+	}
+}
+
+// Returns the count and views of the channel videos created since the given
+// time by video type. Videos are sorted by time, pages stop once a video is
+// older.
+func (e *Exporter) videoStats(name, id string, since time.Time) (map[string]videoStats, error) {
+	e.Logger.Debug("getting channel videos", "channelName", name, "since", since)
+	params := &helix.VideosParams{UserID: id, First: 100, Sort: "time", Type: "all"}
+	stats := make(map[string]videoStats)
+	for {
+		resp, err := e.client.GetVideos(params)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get channel %v videos: %w", name, err)
+		}
+
+		if resp.StatusCode != 200 {
+			return nil, fmt.Errorf("Failed to get channel %v videos, status code %v: %v", name, resp.StatusCode, resp.ErrorMessage)
+		}
+
+		for _, video := range resp.Data.Videos {
+			createdAt, err := time.Parse(time.RFC3339, video.CreatedAt)
+			if err != nil {
+				continue
+			}
+
+			if createdAt.Before(since) {
+				return stats, nil
+			}
+
+			s := stats[video.Type]
+			s.count++
+			s.views += video.ViewCount
+			stats[video.Type] = s
+		}
+
+		if resp.Data.Pagination.Cursor == "" || len(resp.Data.Videos) == 0 {
+			return stats, nil
+		}
+		params.After = resp.Data.Pagination.Cursor
+	}
+}
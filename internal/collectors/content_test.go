@@ -0,0 +1,98 @@
+package collectors
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+const (
+	clipsPath  = "/helix/clips"
+	videosPath = "/helix/videos"
+)
+
+func TestContentCollector(t *testing.T) {
+	recent := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	old := time.Now().Add(-30 * 24 * time.Hour).UTC().Format(time.RFC3339)
+
+	top := fakeResponse{200, `{"data":[{"id":"top","view_count":500}]}`}
+	e, _ := newTestExporter(t, map[string][]fakeResponse{
+		usersPath: {{200, `{"data":[{"id":"1","login":"cool4pso"}]}`}},
+		clipsPath: {
+			top,
+			{200, fmt.Sprintf(`{"data":[{"id":"a","created_at":%q},{"id":"b","created_at":%q},{"id":"c","created_at":%q}]}`, recent, recent, old)},
+			top,
+			{200, fmt.Sprintf(`{"data":[{"id":"a","created_at":%q},{"id":"b","created_at":%q}]}`, recent, recent)},
+			top,
+		},
+		videosPath: {{200, fmt.Sprintf(`{"data":[
+			{"type":"archive","view_count":10,"created_at":%q},
+			{"type":"archive","view_count":5,"created_at":%q},
+			{"type":"highlight","view_count":1,"created_at":%q},
+			{"type":"upload","view_count":100,"created_at":%q}
+		]}`, recent, recent, recent, old)}},
+	}, &Settings{Content: &Content{Interval: time.Nanosecond}})
+
+	s := &scrape{channels: []TwitchChannel{{Name: "cool4pso"}}}
+	c := e.collectors["content"]
+
+	expectedClips := []float64{0, 2, 2}
+	for i, expected := range expectedClips {
+		metrics, err := updateMetrics(t, c, s)
+		if err != nil {
+			t.Fatalf("update %v: expected no error, got %v", i, err)
+		}
+
+		if got := metrics["twitch_clips_created_total{cool4pso}"]; got != expected {
+			t.Errorf("update %v: expected %v clips created, got %v", i, expected, got)
+		}
+	}
+
+	metrics, _ := updateMetrics(t, c, s)
+	expected := map[string]float64{
+		"twitch_clips_top_views{cool4pso}":               500,
+		"twitch_videos_recent{cool4pso,archive}":         2,
+		"twitch_videos_recent_views{cool4pso,archive}":   15,
+		"twitch_videos_recent{cool4pso,highlight}":       1,
+		"twitch_videos_recent{cool4pso,upload}":          0,
+		"twitch_videos_recent_views{cool4pso,upload}":    0,
+		"twitch_videos_recent_views{cool4pso,highlight}": 1,
+	}
+	for name, value := range expected {
+		if got, ok := metrics[name]; !ok || got != value {
+			t.Errorf("expected %v %v, got %v", name, value, metrics)
+		}
+	}
+}
+
+func TestRefreshContentClipOrder(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	cursor := now.Add(-10 * time.Minute)
+	newer := now.Add(-5 * time.Minute)
+
+	// Sorted by views, the clip counted at the cursor comes after a newer one
+	e, _ := newTestExporter(t, map[string][]fakeResponse{
+		clipsPath: {{200, fmt.Sprintf(`{"data":[{"id":"b","created_at":%q},{"id":"a","created_at":%q},{"id":"c","created_at":%q}]}`,
+			newer.Format(time.RFC3339), cursor.Format(time.RFC3339), cursor.Format(time.RFC3339))}},
+		videosPath: {{200, `{"data":[]}`}},
+	}, &Settings{})
+
+	state := &contentState{
+		refreshedAt:   now.Add(-time.Minute),
+		clipCursor:    cursor,
+		clipCursorIDs: map[string]bool{"a": true},
+		clipsCreated:  1,
+	}
+	if err := e.refreshContent("cool4pso", "1", state, time.Hour); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// b and c are new, a was counted before
+	if state.clipsCreated != 3 {
+		t.Errorf("expected 3 clips created, got %v", state.clipsCreated)
+	}
+
+	if !state.clipCursor.Equal(newer) || len(state.clipCursorIDs) != 1 || !state.clipCursorIDs["b"] {
+		t.Errorf("expected cursor %v with clip b, got %v with %v", newer, state.clipCursor, state.clipCursorIDs)
+	}
+}
@@ -0,0 +1,313 @@
+package collectors
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	helix "github.com/nicklaw5/helix/v2"
+)
+
+const (
+	// Used when the discovery interval is not set
+	DefaultDiscoveryInterval = 5 * time.Minute
+	// Live streams discovered per game when the number is not set
+	DefaultDiscoveryTopStreams = 10
+
+	followsDiscoveryScope = "user:read:follows"
+)
+
+// Discovery expands the channel set with the channels found by the enabled
+// sources, refreshed every interval
+type Discovery struct {
+	Interval time.Duration
+	// Channels the authenticated users follow
+	Follows bool
+	// Members of the twitch teams
+	Teams []string
+	// Top live streams of the games
+	GameIDs    []string
+	TopStreams int
+	// Discovered channels must match Include and must not match Exclude
+	Include *regexp.Regexp
+	Exclude *regexp.Regexp
+	// Maximum number of discovered channels, 0 for no limit
+	MaxChannels int
+}
+
+type discoverySource struct {
+	name     string
+	enabled  func(d *Discovery) bool
+	discover func(e *Exporter, d *Discovery) ([]string, error)
+}
+
+// Sources in the order their channels are added, channels of the first
+// sources are kept when the limit is reached
+var discoverySources = []discoverySource{
+	{
+		name:     "follows",
+		enabled:  func(d *Discovery) bool { return d.Follows },
+		discover: (*Exporter).discoverFollows,
+	},
+	{
+		name:     "teams",
+		enabled:  func(d *Discovery) bool { return len(d.Teams) > 0 },
+		discover: (*Exporter).discoverTeams,
+	},
+	{
+		name:     "games",
+		enabled:  func(d *Discovery) bool { return len(d.GameIDs) > 0 },
+		discover: (*Exporter).discoverGames,
+	},
+}
+
+func (d *Discovery) interval() time.Duration {
+	if d == nil || d.Interval <= 0 {
+		return DefaultDiscoveryInterval
+	}
+
+	return d.Interval
+}
+
+func (d *Discovery) matches(login string) bool {
+	if d.Include != nil && !d.Include.MatchString(login) {
+		return false
+	}
+
+	return d.Exclude == nil || !d.Exclude.MatchString(login)
+}
+
+func (e *Exporter) discovery() *Discovery {
+	e.configMu.RLock()
+	defer e.configMu.RUnlock()
+
+	return e.Settings.Discovery
+}
+
+// Runs the discovery every interval, or right away when a reload asks for it
+func (e *Exporter) manageDiscovery() {
+	for {
+		d := e.discovery()
+		if d != nil {
+			e.discoverChannels(d)
+			e.pruneChannels()
+		}
+
+		select {
+		case <-time.After(d.interval()):
+		case <-e.rediscover:
+		}
+	}
+}
+
+// Asks the discovery to run again, used after reloads
+func (e *Exporter) triggerDiscovery() {
+	select {
+	case e.rediscover <- struct{}{}:
+	default:
+	}
+}
+
+// Runs the enabled sources and replaces the discovered channels. A failing
+// source keeps the channels it found the last time it succeeded.
+func (e *Exporter) discoverChannels(d *Discovery) {
+	if e.discoveredLogins == nil {
+		e.discoveredLogins = make(map[string][]string)
+	}
+
+	for _, source := range discoverySources {
+		if !source.enabled(d) {
+			delete(e.discoveredLogins, source.name)
+			continue
+		}
+
+		logins, err := source.discover(e, d)
+		if err != nil {
+			e.Logger.Error("Failed to discover channels", "source", source.name, "err", err)
+			continue
+		}
+
+		e.Logger.Debug("Discovered channels", "source", source.name, "count", len(logins))
+		e.discoveredLogins[source.name] = logins
+	}
+
+	e.configMu.Lock()
+	defer e.configMu.Unlock()
+
+	static := make(map[string]bool)
+	for _, c := range e.Settings.Channels {
+		static[strings.ToLower(c.Name)] = true
+	}
+
+	var channels []TwitchChannel
+	counts := make(map[string]int)
+	for _, source := range discoverySources {
+		for _, login := range e.discoveredLogins[source.name] {
+			login = strings.ToLower(login)
+			if static[login] || !d.matches(login) {
+				continue
+			}
+
+			if d.MaxChannels > 0 && len(channels) >= d.MaxChannels {
+				e.Logger.Warn("Discovered channels limit reached, ignoring the remaining channels", "maxChannels", d.MaxChannels)
+				e.discovered = channels
+				e.discoveredCounts = counts
+				return
+			}
+
+			static[login] = true
+			counts[source.name]++
+			channels = append(channels, TwitchChannel{Name: login})
+		}
+	}
+
+	e.discovered = channels
+	e.discoveredCounts = counts
+}
+
+// Returns the channels followed by the authorized users with the follows scope
+func (e *Exporter) discoverFollows(d *Discovery) ([]string, error) {
+	var logins []string
+	for _, u := range e.sessions {
+		if u.client.GetUserAccessToken() == "" {
+			continue
+		}
+
+		if e.missingScope(u, followsDiscoveryScope) {
+			e.Logger.Warn("Not discovering followed channels, user token is missing scopes", "user", u.name, "missingScopes", []string{followsDiscoveryScope})
+			continue
+		}
+
+		userID, err := e.getUserID(u)
+		if err != nil {
+			return nil, err
+		}
+
+		params := &helix.GetFollowedChannelParams{UserID: userID, First: 100}
+		for {
+			resp, err := u.client.GetFollowedChannels(params)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to get channels followed by %v: %w", u.name, err)
+			}
+
+			if resp.StatusCode != 200 {
+				return nil, fmt.Errorf("Failed to get channels followed by %v, status code %v: %v", u.name, resp.StatusCode, resp.ErrorMessage)
+			}
+
+			for _, channel := range resp.Data.FollowedChannels {
+				logins = append(logins, channel.BroadcaserLogin)
+			}
+
+			if resp.Data.Pagination.Cursor == "" || len(resp.Data.FollowedChannels) == 0 {
+				break
+			}
+			params.After = resp.Data.Pagination.Cursor
+		}
+	}
+
+	return logins, nil
+}
+
+// Returns true if the token of the user is known to miss the scope
+func (e *Exporter) missingScope(u *userSession, scope string) bool {
+	e.stateMu.RLock()
+	defer e.stateMu.RUnlock()
+
+	return u.grantedScopes != nil && !u.grantedScopes[scope]
+}
+
+type team struct {
+	Users []struct {
+		UserLogin string `json:"user_login"`
+	} `json:"users"`
+}
+
+// Returns the members of the teams. The helix client has no teams endpoint,
+// the request is made without it.
+func (e *Exporter) discoverTeams(d *Discovery) ([]string, error) {
+	var logins []string
+	for _, team := range d.Teams {
+		members, err := e.teamMembers(team)
+		if err != nil {
+			return nil, err
+		}
+
+		logins = append(logins, members...)
+	}
+
+	return logins, nil
+}
+
+func (e *Exporter) teamMembers(name string) ([]string, error) {
+	e.Logger.Debug("getting team members", "team", name)
+	teams, err := getAPI[team](e, e.client, "/teams", url.Values{"name": {name}})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get team %v members: %w", name, err)
+	}
+
+	var logins []string
+	for _, t := range teams {
+		for _, u := range t.Users {
+			logins = append(logins, u.UserLogin)
+		}
+	}
+
+	return logins, nil
+}
+
+// Returns the channels of the top live streams of each game, by viewer count
+func (e *Exporter) discoverGames(d *Discovery) ([]string, error) {
+	top := d.TopStreams
+	if top <= 0 {
+		top = DefaultDiscoveryTopStreams
+	}
+
+	var logins []string
+	for _, gameID := range d.GameIDs {
+		e.Logger.Debug("getting top streams", "gameID", gameID, "top", top)
+		params := &helix.StreamsParams{GameIDs: []string{gameID}, Type: "live", First: min(top, 100)}
+		found := 0
+		for found < top {
+			resp, err := e.client.GetStreams(params)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to get game %v streams: %w", gameID, err)
+			}
+
+			if resp.StatusCode != 200 {
+				return nil, fmt.Errorf("Failed to get game %v streams, status code %v: %v", gameID, resp.StatusCode, resp.ErrorMessage)
+			}
+
+			for _, stream := range resp.Data.Streams[:min(top-found, len(resp.Data.Streams))] {
+				logins = append(logins, stream.UserLogin)
+				found++
+			}
+
+			if resp.Data.Pagination.Cursor == "" || len(resp.Data.Streams) == 0 {
+				break
+			}
+			params.After = resp.Data.Pagination.Cursor
+		}
+	}
+
+	return logins, nil
+}
+
+// Returns the configured channels followed by the discovered channels that
+// are not configured
+func (e *Exporter) scrapeChannels() []TwitchChannel {
+	if len(e.discovered) == 0 {
+		return e.Settings.Channels
+	}
+
+	channels := slices.Clone(e.Settings.Channels)
+	for _, c := range e.discovered {
+		if !slices.ContainsFunc(channels, func(s TwitchChannel) bool { return strings.EqualFold(s.Name, c.Name) }) {
+			channels = append(channels, c)
+		}
+	}
+
+	return channels
+}
@@ -0,0 +1,83 @@
+package collectors
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+const (
+	followedPath = "/helix/channels/followed"
+	teamsPath    = "/helix/teams"
+)
+
+func TestDiscoverChannels(t *testing.T) {
+	responses := map[string][]fakeResponse{
+		usersPath:    {{200, `{"data":[{"id":"1","login":"cool4pso"}]}`}},
+		followedPath: {{200, `{"data":[{"broadcaster_login":"followed"}],"pagination":{"cursor":"next"}}`}, {200, `{"data":[{"broadcaster_login":"static"}]}`}},
+		teamsPath:    {{200, `{"data":[{"users":[{"user_login":"member"},{"user_login":"followed"},{"user_login":"member_bot"}]}]}`}},
+		streamsPath:  {{200, `{"data":[{"user_login":"Streamer1"},{"user_login":"streamer2"},{"user_login":"streamer3"}]}`}},
+	}
+
+	tests := []struct {
+		name             string
+		discovery        *Discovery
+		responses        map[string][]fakeResponse
+		expectedChannels []string
+	}{
+		{
+			name:             "Every source",
+			discovery:        &Discovery{Follows: true, Teams: []string{"coolapso"}, GameIDs: []string{"509658"}, TopStreams: 2},
+			responses:        responses,
+			expectedChannels: []string{"followed", "member", "member_bot", "streamer1", "streamer2"},
+		},
+		{
+			name: "Filters",
+			discovery: &Discovery{
+				Teams:   []string{"coolapso"},
+				Include: regexp.MustCompile(`^member`),
+				Exclude: regexp.MustCompile(`_bot$`),
+			},
+			responses:        responses,
+			expectedChannels: []string{"member"},
+		},
+		{
+			name:             "Max channels",
+			discovery:        &Discovery{Teams: []string{"coolapso"}, GameIDs: []string{"509658"}, MaxChannels: 4},
+			responses:        responses,
+			expectedChannels: []string{"member", "followed", "member_bot", "streamer1"},
+		},
+		{
+			name:             "Failed source",
+			discovery:        &Discovery{Teams: []string{"coolapso"}, GameIDs: []string{"509658"}},
+			responses:        map[string][]fakeResponse{teamsPath: {{500, `{"message":"internal error"}`}}, streamsPath: responses[streamsPath]},
+			expectedChannels: []string{"streamer1", "streamer2", "streamer3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestExporter(t, tt.responses, &Settings{
+				Channels:  []TwitchChannel{{Name: "static"}},
+				UserToken: true,
+				Users:     []TwitchUser{{Name: "cool4pso", AccessToken: "token"}},
+				Discovery: tt.discovery,
+			})
+
+			e.discoverChannels(tt.discovery)
+
+			var channels []string
+			for _, c := range e.discovered {
+				channels = append(channels, c.Name)
+			}
+
+			if !slices.Equal(channels, tt.expectedChannels) {
+				t.Errorf("expected channels %v, got %v", tt.expectedChannels, channels)
+			}
+
+			if scraped := e.scrapeChannels(); len(scraped) != len(tt.expectedChannels)+1 {
+				t.Errorf("expected %v channels to scrape, got %v", len(tt.expectedChannels)+1, len(scraped))
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package collectors
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	helix "github.com/nicklaw5/helix/v2"
+)
+
+const (
+	eventSubURL = "wss://eventsub.wss.twitch.tv/ws"
+	// Subscribing to AutoMod events requires this scope, the other moderation
+	// metrics are still collected without it
+	automodScope = "moderator:manage:automod"
+	// Messages are redelivered, the ids of the latest ones are remembered to
+	// count them once
+	eventSubSeenMessages = 100
+)
+
+// AutoMod events of a user channel
+var automodSubscriptions = []string{"automod.message.hold", "automod.message.update"}
+
+type eventSubMessage struct {
+	Metadata struct {
+		MessageID        string `json:"message_id"`
+		MessageType      string `json:"message_type"`
+		SubscriptionType string `json:"subscription_type"`
+	} `json:"metadata"`
+	Payload struct {
+		Session struct {
+			ID                      string `json:"id"`
+			KeepaliveTimeoutSeconds int    `json:"keepalive_timeout_seconds"`
+			ReconnectURL            string `json:"reconnect_url"`
+		} `json:"session"`
+		Subscription struct {
+			Status string `json:"status"`
+		} `json:"subscription"`
+		Event json.RawMessage `json:"event"`
+	} `json:"payload"`
+}
+
+type automodEvent struct {
+	Reason string `json:"reason"`
+	Status string `json:"status"`
+}
+
+// AutoMod messages of a user channel, counted by an EventSub WebSocket
+// listener
+type automodListener struct {
+	mu        sync.Mutex
+	running   bool
+	connected bool
+	// Held messages by reason
+	held map[string]int
+	// Resolved messages by status
+	resolved map[string]int
+	seen     []string
+}
+
+type automodCounts struct {
+	connected bool
+	held      map[string]int
+	resolved  map[string]int
+}
+
+func newAutomodListener() *automodListener {
+	return &automodListener{held: make(map[string]int), resolved: make(map[string]int)}
+}
+
+// Returns a copy of the counts
+func (l *automodListener) counts() automodCounts {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := automodCounts{connected: l.connected, held: make(map[string]int), resolved: make(map[string]int)}
+	for reason, count := range l.held {
+		c.held[reason] = count
+	}
+	for status, count := range l.resolved {
+		c.resolved[status] = count
+	}
+
+	return c
+}
+
+// Marks the listener running, returns false if it already was
+func (l *automodListener) start() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.running {
+		return false
+	}
+	l.running = true
+
+	return true
+}
+
+func (l *automodListener) setConnected(connected bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.connected = connected
+	if !connected {
+		l.seen = nil
+	}
+}
+
+func (l *automodListener) stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.running = false
+	l.connected = false
+}
+
+// Counts the AutoMod notification once per message id
+func (l *automodListener) handle(msg *eventSubMessage) error {
+	var event automodEvent
+	if err := json.Unmarshal(msg.Payload.Event, &event); err != nil {
+		return fmt.Errorf("Failed to parse %v event: %w", msg.Metadata.SubscriptionType, err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, id := range l.seen {
+		if id == msg.Metadata.MessageID {
+			return nil
+		}
+	}
+	l.seen = append(l.seen, msg.Metadata.MessageID)
+	if len(l.seen) > eventSubSeenMessages {
+		l.seen = l.seen[1:]
+	}
+
+	switch msg.Metadata.SubscriptionType {
+	case "automod.message.hold":
+		l.held[event.Reason]++
+	case "automod.message.update":
+		l.resolved[strings.ToLower(event.Status)]++
+	}
+
+	return nil
+}
+
+// Keeps the EventSub connection of the user until the moderation collector is
+// disabled, connecting again with a backoff when it fails
+func (e *Exporter) listenAutomod(u *userSession, l *automodListener) {
+	defer l.stop()
+
+	url := e.eventSubURL
+	if url == "" {
+		url = eventSubURL
+	}
+
+	retry := 0
+	reconnectURL := ""
+	for e.collectorEnabled("moderation") {
+		var err error
+		if reconnectURL != "" {
+			reconnectURL, err = e.eventSubSession(u, l, reconnectURL, false)
+		} else {
+			reconnectURL, err = e.eventSubSession(u, l, url, true)
+		}
+
+		if reconnectURL != "" {
+			e.Logger.Debug("EventSub reconnect requested", "user", u.name)
+			retry = 0
+			continue
+		}
+
+		l.setConnected(false)
+		if err == nil {
+			return
+		}
+
+		e.Logger.Error("EventSub connection failed", "user", u.name, "err", err)
+		time.Sleep(e.retry.delay(retry))
+		retry = min(retry+1, 10)
+	}
+}
+
+// Reads the notifications of an EventSub session, subscribing to the AutoMod
+// events on new sessions. Returns the url to connect to when twitch asks for a
+// reconnect, the subscriptions carry over to it. Returns no error when the
+// moderation collector was disabled.
+func (e *Exporter) eventSubSession(u *userSession, l *automodListener, url string, subscribe bool) (string, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return "", fmt.Errorf("Failed to connect to EventSub: %w", err)
+	}
+	defer conn.Close()
+
+	var welcome eventSubMessage
+	if err := conn.ReadJSON(&welcome); err != nil {
+		return "", fmt.Errorf("Failed to read EventSub welcome: %w", err)
+	}
+
+	if welcome.Metadata.MessageType != "session_welcome" {
+		return "", fmt.Errorf("Failed to start EventSub session: unexpected %v message", welcome.Metadata.MessageType)
+	}
+
+	if subscribe {
+		if err := e.subscribeAutomod(u, welcome.Payload.Session.ID); err != nil {
+			return "", err
+		}
+	}
+
+	l.setConnected(true)
+	e.Logger.Debug("EventSub connected", "user", u.name)
+
+	// Twitch sends a keepalive when there are no notifications, a silent
+	// connection is gone
+	keepalive := time.Duration(welcome.Payload.Session.KeepaliveTimeoutSeconds)*time.Second + 10*time.Second
+	for e.collectorEnabled("moderation") {
+		if err := conn.SetReadDeadline(time.Now().Add(keepalive)); err != nil {
+			return "", err
+		}
+
+		var msg eventSubMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return "", fmt.Errorf("Failed to read EventSub message: %w", err)
+		}
+
+		switch msg.Metadata.MessageType {
+		case "notification":
+			if err := l.handle(&msg); err != nil {
+				e.Logger.Error(err.Error(), "user", u.name)
+			}
+		case "session_reconnect":
+			return msg.Payload.Session.ReconnectURL, nil
+		case "revocation":
+			return "", fmt.Errorf("EventSub subscription revoked: %v", msg.Payload.Subscription.Status)
+		}
+	}
+
+	return "", nil
+}
+
+func (e *Exporter) subscribeAutomod(u *userSession, sessionID string) error {
+	userID, err := e.getUserID(u)
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range automodSubscriptions {
+		resp, err := u.client.CreateEventSubSubscription(&helix.EventSubSubscription{
+			Type:    subscription,
+			Version: "2",
+			Condition: helix.EventSubCondition{
+				BroadcasterUserID: userID,
+				ModeratorUserID:   userID,
+			},
+			Transport: helix.EventSubTransport{Method: "websocket", SessionID: sessionID},
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to subscribe %v to %v: %w", u.name, subscription, err)
+		}
+
+		if resp.StatusCode != 202 {
+			return fmt.Errorf("Failed to subscribe %v to %v, status code %v: %v", u.name, subscription, resp.StatusCode, resp.ErrorMessage)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package collectors
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const eventSubSubscriptionsPath = "/helix/eventsub/subscriptions"
+
+func eventSubNotification(id, subscriptionType, event string) string {
+	return fmt.Sprintf(`{"metadata":{"message_id":%q,"message_type":"notification","subscription_type":%q},"payload":{"event":%v}}`, id, subscriptionType, event)
+}
+
+// Fake EventSub server, sends the messages of the path then keepalives until
+// the connection is closed. {{host}} is replaced by the server host.
+func newFakeEventSub(t *testing.T, messages map[string][]string) *httptest.Server {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for _, msg := range messages[r.URL.Path] {
+			msg = strings.ReplaceAll(msg, "{{host}}", r.Host)
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+				return
+			}
+		}
+
+		for {
+			time.Sleep(10 * time.Millisecond)
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"metadata":{"message_type":"session_keepalive"}}`)); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestAutomodListener(t *testing.T) {
+	welcome := `{"metadata":{"message_type":"session_welcome"},"payload":{"session":{"id":"session","keepalive_timeout_seconds":10}}}`
+	srv := newFakeEventSub(t, map[string][]string{
+		"/ws": {
+			welcome,
+			eventSubNotification("1", "automod.message.hold", `{"reason":"automod"}`),
+			// Redelivered messages are counted once
+			eventSubNotification("1", "automod.message.hold", `{"reason":"automod"}`),
+			eventSubNotification("2", "automod.message.hold", `{"reason":"blocked_term"}`),
+			eventSubNotification("3", "automod.message.update", `{"status":"Approved"}`),
+			`{"metadata":{"message_type":"session_reconnect"},"payload":{"session":{"reconnect_url":"ws://{{host}}/reconnect"}}}`,
+		},
+		"/reconnect": {
+			welcome,
+			eventSubNotification("4", "automod.message.update", `{"status":"Denied"}`),
+		},
+	})
+
+	e, fake := newTestExporter(t, map[string][]fakeResponse{
+		usersPath:                 {{200, `{"data":[{"id":"1","login":"cool4pso"}]}`}},
+		eventSubSubscriptionsPath: {{202, `{"data":[{"id":"sub","status":"enabled"}]}`}},
+	}, &Settings{
+		UserToken:  true,
+		Users:      []TwitchUser{{Name: "cool4pso", AccessToken: "token"}},
+		Collectors: map[string]bool{"moderation": true},
+	})
+	e.eventSubURL = "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	l := newAutomodListener()
+	l.start()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.listenAutomod(e.sessions[0], l)
+	}()
+
+	expected := automodCounts{
+		connected: true,
+		held:      map[string]int{"automod": 1, "blocked_term": 1},
+		resolved:  map[string]int{"approved": 1, "denied": 1},
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	var counts automodCounts
+	for time.Now().Before(deadline) {
+		counts = l.counts()
+		if counts.resolved["denied"] == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if fmt.Sprint(counts) != fmt.Sprint(expected) {
+		t.Errorf("expected counts %v, got %v", expected, counts)
+	}
+
+	// Subscriptions carry over to the reconnected session
+	if calls := fake.callCount(eventSubSubscriptionsPath); calls != len(automodSubscriptions) {
+		t.Errorf("expected %v subscription requests, got %v", len(automodSubscriptions), calls)
+	}
+
+	// Disabling the collector stops the listener
+	e.configMu.Lock()
+	e.Settings.Collectors["moderation"] = false
+	e.configMu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the listener to stop")
+	}
+
+	if counts := l.counts(); counts.connected {
+		t.Errorf("expected the listener to be disconnected")
+	}
+}
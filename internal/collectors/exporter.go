@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"log"
 	"log/slog"
-	"strings"
+	"sync"
 	"time"
 
 	helix "github.com/nicklaw5/helix/v2"
@@ -15,93 +15,117 @@ const (
 	namespace = "twitch"
 )
 
-var (
-	userTokenScopes = []string{"channel:read:subscriptions"}
-)
-
 type TwitchChannel struct {
-	Name        string
-	ViewerCount int
-	SubCount    int
+	Name            string
+	ViewerCount     int
+	SubCount        int
+	Labels          map[string]string
+	Collectors      []string
+	RefreshInterval time.Duration
 }
 
 type ApiSettings struct {
-	Options           helix.Options
-	appTokenIssuedAt  time.Time
-	appTokenExpireIn  int
-	AuthorizationURL  string
-	AuthorizationCode string
+	Options          helix.Options
+	appTokenIssuedAt time.Time
+	appTokenExpireIn int
 }
 
 type Settings struct {
 	ApiSettings ApiSettings
 	Channels    []TwitchChannel
 	UserToken   bool
-	User        TwitchChannel
+	Users       []TwitchUser
 	LogLevel    string
 	LogFormat   string
 	MetricsPath string
 	ListenPort  string
 	Address     string
+	Collectors  map[string]bool
+	Modules     map[string]ProbeModule
+	AdminToken  string
+	Discovery   *Discovery
+	Categories  *Categories
+	Content     *Content
+	Viewers     *Viewers
+	// Read again before the tokens are checked, to pick up rotated secrets
+	ClientSecretFile string
+	// Directory where collectors keep state across restarts, state is only
+	// kept in memory when empty
+	StateDir string
 }
 
+// Metrics about the exporter itself, the twitch metrics are described by
+// each collector
 type metrics struct {
-	isLive        *prometheus.Desc
-	viewerCount   *prometheus.Desc
-	subCount      *prometheus.Desc
-	followerCount *prometheus.Desc
+	missingScope      *prometheus.Desc
+	tokenExpiry       *prometheus.Desc
+	tokenValid        *prometheus.Desc
+	tokenRefresh      *prometheus.Desc
+	configReload      *prometheus.Desc
+	collectorDuration *prometheus.Desc
+	collectorSuccess  *prometheus.Desc
+	discovered        *prometheus.Desc
 }
 
 type Exporter struct {
-	client   *helix.Client
-	metrics  *metrics
-	Settings *Settings
-	Logger   *slog.Logger
+	client       *helix.Client
+	authClient   *helix.Client
+	sessions     []*userSession
+	retry        retryPolicy
+	metrics      *metrics
+	collectors   map[string]collector
+	labelNames   []string
+	tokenMu      sync.Mutex
+	stateMu      sync.RWMutex
+	cacheMu      sync.Mutex
+	channelCache map[string]channelState
+	idCache      map[string]string
+	appToken     tokenHealth
+	clientSecret secretFile
+	// Guards the settings changed by reloads, the channel list, collectors
+	// and the channel metrics labels
+	configMu             sync.RWMutex
+	reloadMu             sync.Mutex
+	lastReloadSuccessful bool
+	// Channels found by the discovery, guarded by configMu
+	discovered       []TwitchChannel
+	discoveredCounts map[string]int
+	// Only used by the discovery goroutine
+	discoveredLogins map[string][]string
+	rediscover       chan struct{}
+	// EventSub WebSocket url, the twitch one when empty
+	eventSubURL string
+	Settings    *Settings
+	Logger      *slog.Logger
+	// LoadSettings returns the settings to apply on reload
+	LoadSettings func() (*Settings, error)
 }
 
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	ch <- e.metrics.isLive
-	ch <- e.metrics.viewerCount
-	ch <- e.metrics.subCount
-	ch <- e.metrics.followerCount
-}
-
-func (e *Exporter) handleAppTokens() {
-	if e.isAppTokenExpired() {
-		e.Logger.Info("getting new application token")
-		e.setNewAppToken()
-	}
+	for _, name := range Collectors() {
+		e.collectors[name].Describe(ch)
+	}
+
+	ch <- e.metrics.missingScope
+	ch <- e.metrics.tokenExpiry
+	ch <- e.metrics.tokenValid
+	ch <- e.metrics.tokenRefresh
+	ch <- e.metrics.configReload
+	ch <- e.metrics.collectorDuration
+	ch <- e.metrics.collectorSuccess
+	ch <- e.metrics.discovered
 }
 
-func (e *Exporter) handleUserTokens() error {
-	apiSettings := e.Settings.ApiSettings
-
-	if apiSettings.Options.UserAccessToken == "" {
-		if apiSettings.AuthorizationCode == "" {
-			return fmt.Errorf("Authentication flow not completed, please use the authorization url to obtain an access token, or provide an access token")
-		}
-
-		e.setNewUserToken()
-		return nil
-	}
-
-	if !e.isUserTokenValid() {
-		if apiSettings.Options.RefreshToken == "" {
-			return fmt.Errorf("Access token available, but no refresh token provided, Please re-authenticate again, or provide a refresh token")
-		}
-
-		e.Logger.Info("User token no longer valid, refreshing")
-		e.refreshUserToken()
-		return nil
-	}
+func (e *Exporter) currentLabelNames() []string {
+	e.configMu.RLock()
+	defer e.configMu.RUnlock()
 
-	e.Logger.Debug("token is valid")
-	return nil
+	return e.labelNames
 }
 
 func (e *Exporter) collectUserMetrics() bool {
 	if e.Settings.UserToken {
-		if e.Settings.User.Name == "" {
+		if len(e.sessions) == 0 {
 			e.Logger.Warn("User token provided, but no user was provided, consider removing the --user.token flag or set a user to monitor. Not scraping user metrics")
 
 			return false
@@ -114,307 +138,246 @@ func (e *Exporter) collectUserMetrics() bool {
 }
 
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	if e.Settings.UserToken {
-		err := e.handleUserTokens()
-		if err != nil {
-			e.Logger.Error(err.Error())
-			return
+	e.collectTokenHealth(ch)
+
+	// Settings changed by reloads are read once per scrape
+	e.configMu.RLock()
+	s := &scrape{
+		channels:   e.scrapeChannels(),
+		labelNames: e.labelNames,
+	}
+	reloadSuccessful := e.lastReloadSuccessful
+	discoveryEnabled := e.Settings.Discovery != nil
+	discoveredCounts := e.discoveredCounts
+	e.configMu.RUnlock()
+
+	lastReload := 0
+	if reloadSuccessful {
+		lastReload = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		e.metrics.configReload,
+		prometheus.GaugeValue,
+		float64(lastReload),
+	)
+
+	if discoveryEnabled {
+		for _, source := range discoverySources {
+			ch <- prometheus.MustNewConstMetric(
+				e.metrics.discovered,
+				prometheus.GaugeValue,
+				float64(discoveredCounts[source.name]),
+				source.name,
+			)
 		}
-	} else {
-		e.handleAppTokens()
 	}
 
-	for _, twitchChannel := range e.Settings.Channels {
+	e.runCollectors(s, ch)
 
-		ch <- prometheus.MustNewConstMetric(
-			e.metrics.isLive,
-			prometheus.GaugeValue,
-			float64(e.isLive(twitchChannel.Name)),
-			twitchChannel.Name,
-		)
+	if e.collectUserMetrics() {
+		for _, u := range e.sessions {
+			e.collectMissingScopes(ch, u)
+		}
+	}
+}
 
-		ch <- prometheus.MustNewConstMetric(
-			e.metrics.viewerCount,
-			prometheus.GaugeValue,
-			float64(e.viewerCount(twitchChannel.Name)),
-			twitchChannel.Name,
-		)
+func (e *Exporter) collectTokenHealth(ch chan<- prometheus.Metric) {
+	e.stateMu.RLock()
+	defer e.stateMu.RUnlock()
+
+	e.collectToken(ch, &e.appToken, appTokenType, "")
+	for _, u := range e.sessions {
+		e.collectToken(ch, &u.token, userTokenType, u.name)
 	}
+}
 
-	if e.collectUserMetrics() {
+func (e *Exporter) collectToken(ch chan<- prometheus.Metric, h *tokenHealth, tokenType, user string) {
+	valid := 0
+	if h.isValid() {
+		valid = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		e.metrics.tokenValid,
+		prometheus.GaugeValue,
+		float64(valid),
+		tokenType, user,
+	)
+
+	if !h.expiresAt.IsZero() {
 		ch <- prometheus.MustNewConstMetric(
-			e.metrics.subCount,
+			e.metrics.tokenExpiry,
 			prometheus.GaugeValue,
-			float64(e.subCount()),
-			e.Settings.User.Name,
+			float64(h.expiresAt.Unix()),
+			tokenType, user,
 		)
+	}
 
+	for _, result := range []string{"success", "failure"} {
 		ch <- prometheus.MustNewConstMetric(
-			e.metrics.followerCount,
-			prometheus.GaugeValue,
-			float64(e.followerCount()),
-			e.Settings.User.Name,
+			e.metrics.tokenRefresh,
+			prometheus.CounterValue,
+			float64(h.refreshes[result]),
+			tokenType, user, result,
 		)
 	}
 }
 
-// Returns 1 if broadcasting, 0 if not
-func (e *Exporter) isLive(channelName string) int {
-	e.Logger.Debug("getting channel status", "channelName", channelName)
-	resp, err := e.client.SearchChannels(&helix.SearchChannelsParams{
-		Channel: channelName,
-	})
-	if err != nil {
-		e.Logger.Error("Failed to get channel status", "err", err)
-		return 0
-	}
+func (e *Exporter) collectMissingScopes(ch chan<- prometheus.Metric, u *userSession) {
+	e.stateMu.RLock()
+	defer e.stateMu.RUnlock()
 
-	if resp.StatusCode != 200 {
-		e.Logger.Error("Failed to get channel status", "statusCude", resp.StatusCode, "err", resp.ErrorMessage)
-		return 0
+	if u.grantedScopes == nil {
+		return
 	}
 
-	for _, channel := range resp.Data.Channels {
-		if strings.EqualFold(channel.DisplayName, channelName) && channel.IsLive {
-			return 1
+	for _, scope := range e.requiredScopes() {
+		missing := 0
+		if !u.grantedScopes[scope] {
+			missing = 1
 		}
-	}
-
-	return 0
-}
 
-func (e *Exporter) viewerCount(channelName string) int {
-	e.Logger.Debug("getting viewer count", "channelName", channelName)
-	resp, err := e.client.GetStreams(&helix.StreamsParams{
-		UserLogins: []string{channelName},
-	})
-	if err != nil {
-		e.Logger.Error("Failed to get viewer count", "err", err)
-		return 0
-	}
-
-	if resp.StatusCode != 200 {
-		e.Logger.Error("Failed to get viewer count", "statusCode", resp.StatusCode, "err", resp.ErrorMessage)
-		return 0
-	}
-
-	if len(resp.Data.Streams) <= 0 {
-		return 0
+		ch <- prometheus.MustNewConstMetric(
+			e.metrics.missingScope,
+			prometheus.GaugeValue,
+			float64(missing),
+			u.name, scope,
+		)
 	}
-
-	vc := resp.Data.Streams[0].ViewerCount
-	e.Logger.Debug("Got channel viewer count", "channelName", channelName, "count", vc)
-	return vc
 }
 
-func (e *Exporter) getUserID() string {
-	e.Logger.Debug("getting user ID", "user", e.Settings.User.Name)
-	resp, err := e.client.GetUsers(&helix.UsersParams{
-		Logins: []string{e.Settings.User.Name},
+func (e *Exporter) getUserID(u *userSession) (string, error) {
+	e.Logger.Debug("getting user ID", "user", u.name)
+	resp, err := u.client.GetUsers(&helix.UsersParams{
+		Logins: []string{u.name},
 	})
 	if err != nil {
-		e.Logger.Error("Failed to get user id", "err", err)
-		return ""
+		return "", fmt.Errorf("Failed to get user %v id: %w", u.name, err)
 	}
 
 	if resp.StatusCode != 200 {
-		e.Logger.Error("Failed to get user id", "statusCode", resp.StatusCode, "err", resp.ErrorMessage)
-		return ""
+		return "", fmt.Errorf("Failed to get user %v id, status code %v: %v", u.name, resp.StatusCode, resp.ErrorMessage)
 	}
 
-	var user helix.User
-	for _, u := range resp.Data.Users {
-		if u.Login == e.Settings.User.Name {
-			user = u
+	var userID string
+	for _, user := range resp.Data.Users {
+		if user.Login == u.name {
+			userID = user.ID
 		}
 	}
-	userID := user.ID
-
 	if userID == "" {
-		e.Logger.Error(fmt.Sprintf("Could not find user with login %v", e.Settings.User))
-		return ""
+		return "", fmt.Errorf("Could not find user with login %v", u.name)
 	}
 
 	e.Logger.Debug("user ID found", "userID", userID)
-	return userID
-}
-
-// TODO: Add more granularity on the metrics, by gifted and tier
-func (e *Exporter) subCount() int {
-	e.Logger.Debug("getting user sub count")
-	resp, err := e.client.GetSubscriptions(&helix.SubscriptionsParams{
-		BroadcasterID: e.getUserID(),
-	})
-
-	if err != nil {
-		e.Logger.Error("Failed to get subscribers", "err", err)
-		return 0
-	}
-
-	if resp.StatusCode != 200 {
-		e.Logger.Error("Failed to get subscribers", "statusCode", resp.StatusCode, "err", resp.ErrorMessage)
-		return 0
-	}
-
-	sc := len(resp.Data.Subscriptions)
-	e.Logger.Debug("got subcount", "subCount", sc)
-	return sc
+	return userID, nil
 }
 
-func (e *Exporter) followerCount() int {
-	e.Logger.Debug("getting user follower count")
-	resp, err := e.client.GetChannelFollows(&helix.GetChannelFollowsParams{
-		BroadcasterID: e.getUserID(),
-		First:         1,
-	})
-
-	if err != nil {
-		e.Logger.Error("Failed to get followers", "err", err)
-	}
+func newMetrics() *metrics {
+	return &metrics{
 
-	if resp.StatusCode != 200 {
-		e.Logger.Error("Failed to get followers", "statusCode", resp.StatusCode, "err", resp.ErrorMessage)
-	}
+		missingScope: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "token", "missing_scope"),
+			"If a scope required by the enabled collectors was not granted to the user token",
+			[]string{"user", "scope"}, nil,
+		),
 
-	fc := resp.Data.Total
-	e.Logger.Debug("got channel follower count", "channelName", e.Settings.User.Name, "count", fc)
+		tokenExpiry: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "token", "expiry_timestamp_seconds"),
+			"Unix timestamp at which the token expires",
+			[]string{"type", "user"}, nil,
+		),
 
-	return resp.Data.Total
-}
+		tokenValid: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "token", "valid"),
+			"If the token is valid",
+			[]string{"type", "user"}, nil,
+		),
 
-func (e *Exporter) isUserTokenValid() bool {
-	e.Logger.Debug("validating user token")
-	apiSettings := e.Settings.ApiSettings
-	isValid, resp, err := e.client.ValidateToken(apiSettings.Options.UserAccessToken)
-	if err != nil {
-		e.Logger.Error("Failed to validate Token", "err", err)
-		return false
-	}
+		tokenRefresh: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "token", "refresh_total"),
+			"Number of token requests and refreshes by result",
+			[]string{"type", "user", "result"}, nil,
+		),
 
-	if resp.StatusCode != 200 {
-		e.Logger.Error("Failed to validate Token", "statusCode", resp.StatusCode, "err", resp.ErrorMessage)
-		return false
-	}
+		configReload: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "exporter", "config_last_reload_successful"),
+			"If the last settings reload was successful",
+			nil, nil,
+		),
 
-	return isValid
-}
+		collectorDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "exporter", "collector_duration_seconds"),
+			"Duration of the collector on the last scrape",
+			[]string{"collector"}, nil,
+		),
 
-func (e *Exporter) isAppTokenExpired() bool {
-	e.Logger.Debug("checking if application token is expired")
-	apiSettings := e.Settings.ApiSettings
-	secondsSinceIssued := time.Since(apiSettings.appTokenIssuedAt).Seconds()
-	thirtyMinutesInSeconds := 1800
+		collectorSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "exporter", "collector_success"),
+			"If the collector succeeded on the last scrape",
+			[]string{"collector"}, nil,
+		),
 
-	if apiSettings.appTokenExpireIn <= 0 {
-		return true
+		discovered: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "discovery", "channels"),
+			"Number of channels added by the discovery source",
+			[]string{"source"}, nil,
+		),
 	}
-
-	return int(secondsSinceIssued) >= (apiSettings.appTokenExpireIn - thirtyMinutesInSeconds)
 }
 
-func (e *Exporter) setNewAppToken() {
-	e.Logger.Debug("setting new application token")
-	resp, err := e.client.RequestAppAccessToken([]string{"user:read:email"})
-	if err != nil {
-		e.Logger.Error("Failed to request app access token", "err", err)
-	}
-
-	if resp.StatusCode != 200 {
-		e.Logger.Error("Failed to request app access token", "statusCode", resp.StatusCode, "err", resp.ErrorMessage)
-	}
+// Creates the client used for the api requests with the application token.
+// The user tokens are kept by each user session.
+func newHelixClient(s *Settings) (*helix.Client, error) {
+	options := s.ApiSettings.Options
+	options.UserAccessToken = ""
+	options.RefreshToken = ""
 
-	e.client.SetAppAccessToken(resp.Data.AccessToken)
-	e.Settings.ApiSettings.appTokenExpireIn = resp.Data.ExpiresIn
-	e.Settings.ApiSettings.appTokenIssuedAt = time.Now()
-	e.Logger.Debug("new application token set")
+	return helix.NewClient(&options)
 }
 
-func (e *Exporter) setNewUserToken() {
-	e.Logger.Debug("setting new user token")
-	resp, err := e.client.RequestUserAccessToken(e.Settings.ApiSettings.AuthorizationCode)
-	if err != nil {
-		e.Logger.Error("Failed to request user access token", "err", err)
-	}
-
-	if resp.StatusCode != 200 {
-		e.Logger.Error("Failed to request user access token", "statusCode", resp.StatusCode, "err", resp.ErrorMessage)
-	}
-
-	e.client.SetUserAccessToken(resp.Data.AccessToken)
-	e.Settings.ApiSettings.Options.RefreshToken = resp.Data.RefreshToken
-	e.Logger.Debug("new user token set")
+// Creates the client used to request, refresh and validate tokens
+func newAuthClient(s *Settings) (*helix.Client, error) {
+	return helix.NewClient(&helix.Options{
+		ClientID:     s.ApiSettings.Options.ClientID,
+		ClientSecret: s.ApiSettings.Options.ClientSecret,
+		RedirectURI:  s.ApiSettings.Options.RedirectURI,
+		HTTPClient:   s.ApiSettings.Options.HTTPClient,
+	})
 }
 
-func (e *Exporter) refreshUserToken() {
-	e.Logger.Debug("refreshing user token")
-	resp, err := e.client.RefreshUserAccessToken(e.Settings.ApiSettings.Options.RefreshToken)
-	if err != nil {
-		e.Logger.Error("Failed to refresh user access token", "err", err)
-	}
-
-	if resp.StatusCode != 200 {
-		e.Logger.Error("Failed to refresh user access token", "statusCode", resp.StatusCode, "err", resp.ErrorMessage)
+func newUserSessions(s *Settings, authClient *helix.Client, logger *slog.Logger) ([]*userSession, error) {
+	if !s.UserToken {
+		return nil, nil
 	}
 
-	e.client.SetUserAccessToken(resp.Data.AccessToken)
-	e.Settings.ApiSettings.Options.RefreshToken = resp.Data.RefreshToken
-	e.Logger.Debug("user token refreshed")
-}
-
-func newMetrics() *metrics {
-	return &metrics{
-		isLive: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "is_live"),
-			"If twitch channel is broadcasting",
-			[]string{"name"}, nil,
-		),
-
-		viewerCount: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "viewer_total"),
-			"Channel current viewer count",
-			[]string{"name"}, nil,
-		),
-
-		subCount: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "subscribers_total"),
-			"Channel current total subscribers",
-			[]string{"name"}, nil,
-		),
-
-		followerCount: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "followers_total"),
-			"Channel total number of followers",
-			[]string{"name"}, nil,
-		),
-	}
-}
-
-func newHelixClient(s *Settings, logger *slog.Logger) (*helix.Client, error) {
-	var client *helix.Client
-	var err error
-
-	if s.UserToken {
-		client, err = helix.NewClient(&s.ApiSettings.Options)
+	var sessions []*userSession
+	for _, user := range s.Users {
+		u, err := newUserSession(s, user, authClient)
 		if err != nil {
 			return nil, err
 		}
 
-		s.ApiSettings.AuthorizationURL = client.GetAuthorizationURL(&helix.AuthorizationURLParams{
-			ResponseType: "code",
-			Scopes:       userTokenScopes,
-			State:        "prometheus-twitch-exporter",
-			ForceVerify:  false,
-		})
-		logger.Info(fmt.Sprintf("twitch authorization url: %v", s.ApiSettings.AuthorizationURL))
-
-		return client, err
+		logger.Info(fmt.Sprintf("twitch authorization url: %v", u.authorizationURL), "user", u.name)
+		sessions = append(sessions, u)
 	}
 
-	return helix.NewClient(&s.ApiSettings.Options)
+	return sessions, nil
 }
 
 func NewExporter(s *Settings, logger *slog.Logger) (*Exporter, error) {
-	client, err := newHelixClient(s, logger)
+	client, err := newHelixClient(s)
+	if err != nil {
+		log.Fatalf("Failed to create twitch client %v", err)
+	}
+
+	authClient, err := newAuthClient(s)
+	if err != nil {
+		log.Fatalf("Failed to create twitch client %v", err)
+	}
+
+	sessions, err := newUserSessions(s, authClient, logger)
 	if err != nil {
 		log.Fatalf("Failed to create twitch client %v", err)
 	}
@@ -422,11 +385,30 @@ func NewExporter(s *Settings, logger *slog.Logger) (*Exporter, error) {
 	metrics := newMetrics()
 
 	exporter := &Exporter{
-		client:   client,
-		metrics:  metrics,
-		Settings: s,
-		Logger:   logger,
-	}
+		client:               client,
+		authClient:           authClient,
+		sessions:             sessions,
+		retry:                defaultRetryPolicy,
+		metrics:              metrics,
+		labelNames:           channelLabelNames(s.Channels),
+		channelCache:         make(map[string]channelState),
+		idCache:              make(map[string]string),
+		clientSecret:         secretFile{path: s.ClientSecretFile, value: s.ApiSettings.Options.ClientSecret},
+		lastReloadSuccessful: true,
+		discoveredLogins:     make(map[string][]string),
+		rediscover:           make(chan struct{}, 1),
+		Settings:             s,
+		Logger:               logger,
+	}
+
+	exporter.collectors = newCollectors(exporter)
+
+	exporter.handleTokens()
+	for _, u := range sessions {
+		exporter.logMissingScopes(u)
+	}
+	go exporter.manageTokens()
+	go exporter.manageDiscovery()
 
 	return exporter, nil
 }
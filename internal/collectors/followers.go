@@ -0,0 +1,222 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	helix "github.com/nicklaw5/helix/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reading the followers beyond the total requires this scope, the follower
+// count is still collected without it
+const followerListScope = "moderator:read:followers"
+
+// Window of the follows per hour rate
+const followRateWindow = time.Hour
+
+func init() {
+	registerCollector(collectorInfo{
+		name:           "followers",
+		scope:          userScope,
+		defaultEnabled: true,
+		scopes:         []string{},
+		optionalScopes: []string{followerListScope},
+		factory:        newFollowersCollector,
+	})
+}
+
+// New follows of a user. Follows are sorted from the newest, pages are read
+// until the cursor, the follows at the cursor were already counted.
+type followState struct {
+	initialized bool
+	cursor      time.Time
+	cursorIDs   map[string]bool
+	new         int
+	// Follow times within the rate window
+	recent []time.Time
+}
+
+// Collects the follower count and new followers of the authenticated users
+type followersCollector struct {
+	e             *Exporter
+	followerCount *prometheus.Desc
+	newFollowers  *prometheus.Desc
+	followRate    *prometheus.Desc
+
+	mu     sync.Mutex
+	states map[string]*followState
+}
+
+func newFollowersCollector(e *Exporter) collector {
+	return &followersCollector{
+		e: e,
+		followerCount: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "followers_total"),
+			"Channel total number of followers",
+			[]string{"name"}, nil,
+		),
+		newFollowers: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "followers", "new_total"),
+			"Number of follows since the exporter started",
+			[]string{"name"}, nil,
+		),
+		followRate: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "followers", "per_hour"),
+			"Number of follows within the last hour",
+			[]string{"name"}, nil,
+		),
+		states: make(map[string]*followState),
+	}
+}
+
+func (c *followersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.followerCount
+	ch <- c.newFollowers
+	ch <- c.followRate
+}
+
+func (c *followersCollector) Update(s *scrape, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for _, u := range c.e.sessionsFor("followers") {
+		if c.e.missingScope(u, followerListScope) {
+			c.e.Logger.Debug("Not collecting new followers, user token is missing scopes", "user", u.name, "missingScopes", []string{followerListScope})
+			count, err := c.e.followerCount(u)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			ch <- prometheus.MustNewConstMetric(c.followerCount, prometheus.GaugeValue, float64(count), u.name)
+			continue
+		}
+
+		state, ok := c.states[u.name]
+		if !ok {
+			state = &followState{}
+			c.states[u.name] = state
+		}
+
+		count, err := c.e.newFollows(u, state, time.Now())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.followerCount, prometheus.GaugeValue, float64(count), u.name)
+		ch <- prometheus.MustNewConstMetric(c.newFollowers, prometheus.CounterValue, float64(state.new), u.name)
+		ch <- prometheus.MustNewConstMetric(c.followRate, prometheus.GaugeValue, float64(len(state.recent)), u.name)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (e *Exporter) followerCount(u *userSession) (int, error) {
+	e.Logger.Debug("getting user follower count", "user", u.name)
+	userID, err := e.getUserID(u)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := u.client.GetChannelFollows(&helix.GetChannelFollowsParams{
+		BroadcasterID: userID,
+		First:         1,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get %v followers: %w", u.name, err)
+	}
+
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("Failed to get %v followers, status code %v: %v", u.name, resp.StatusCode, resp.ErrorMessage)
+	}
+
+	fc := resp.Data.Total
+	e.Logger.Debug("got channel follower count", "channelName", u.name, "count", fc)
+
+	return fc, nil
+}
+
+// Reads the follows newer than the cursor into the state and returns the
+// follower count. The first read only fills the rate window, the follows
+// before the exporter started are not new. The state is only changed when
+// every page is read.
+func (e *Exporter) newFollows(u *userSession, state *followState, now time.Time) (int, error) {
+	e.Logger.Debug("getting user new followers", "user", u.name, "since", state.cursor)
+	userID, err := e.getUserID(u)
+	if err != nil {
+		return 0, err
+	}
+
+	windowStart := now.Add(-followRateWindow)
+	next := *state
+	next.recent = nil
+	for _, followedAt := range state.recent {
+		if followedAt.After(windowStart) {
+			next.recent = append(next.recent, followedAt)
+		}
+	}
+
+	params := &helix.GetChannelFollowsParams{BroadcasterID: userID, First: 100}
+	total := 0
+	first := true
+pages:
+	for {
+		resp, err := u.client.GetChannelFollows(params)
+		if err != nil {
+			return 0, fmt.Errorf("Failed to get %v followers: %w", u.name, err)
+		}
+
+		if resp.StatusCode != 200 {
+			return 0, fmt.Errorf("Failed to get %v followers, status code %v: %v", u.name, resp.StatusCode, resp.ErrorMessage)
+		}
+
+		total = resp.Data.Total
+		for _, follow := range resp.Data.Channels {
+			followedAt := follow.Followed.Time
+			if first {
+				next.cursor = followedAt
+				next.cursorIDs = make(map[string]bool)
+				first = false
+			}
+			if followedAt.Equal(next.cursor) {
+				next.cursorIDs[follow.UserID] = true
+			}
+
+			if !state.initialized {
+				if !followedAt.After(windowStart) {
+					break pages
+				}
+				next.recent = append(next.recent, followedAt)
+				continue
+			}
+
+			if followedAt.Before(state.cursor) {
+				break pages
+			}
+
+			if followedAt.Equal(state.cursor) && state.cursorIDs[follow.UserID] {
+				continue
+			}
+
+			next.new++
+			if followedAt.After(windowStart) {
+				next.recent = append(next.recent, followedAt)
+			}
+		}
+
+		if resp.Data.Pagination.Cursor == "" || len(resp.Data.Channels) == 0 {
+			break
+		}
+		params.After = resp.Data.Pagination.Cursor
+	}
+
+	next.initialized = true
+	*state = next
+
+	return total, nil
+}
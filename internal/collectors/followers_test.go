@@ -0,0 +1,96 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewFollows(t *testing.T) {
+	e, fake := newTestExporter(t, map[string][]fakeResponse{
+		usersPath: {{200, `{"data":[{"id":"1","login":"cool4pso"}]}`}},
+		followersPath: {
+			{200, `{"total":3,"data":[{"user_id":"a","followed_at":"2026-10-18T11:50:00Z"},{"user_id":"b","followed_at":"2026-10-18T11:30:00Z"},{"user_id":"c","followed_at":"2026-10-18T10:00:00Z"}]}`},
+			{200, `{"total":4,"data":[{"user_id":"d","followed_at":"2026-10-18T11:55:00Z"},{"user_id":"e","followed_at":"2026-10-18T11:50:00Z"}],"pagination":{"cursor":"next"}}`},
+			{200, `{"total":4,"data":[{"user_id":"a","followed_at":"2026-10-18T11:50:00Z"},{"user_id":"b","followed_at":"2026-10-18T11:30:00Z"}]}`},
+		},
+	}, &Settings{
+		UserToken:  true,
+		Users:      []TwitchUser{{Name: "cool4pso", AccessToken: "token"}},
+		Collectors: map[string]bool{"followers": true},
+	})
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		now            time.Time
+		expectedTotal  int
+		expectedNew    int
+		expectedRecent int
+	}{
+		{
+			name:           "Follows before the exporter started are not new",
+			now:            now,
+			expectedTotal:  3,
+			expectedNew:    0,
+			expectedRecent: 2,
+		},
+		{
+			name:           "Follows after the cursor and at the cursor are new",
+			now:            now,
+			expectedTotal:  4,
+			expectedNew:    2,
+			expectedRecent: 4,
+		},
+		{
+			name:           "Follows leave the rate window",
+			now:            now.Add(time.Hour),
+			expectedTotal:  4,
+			expectedNew:    2,
+			expectedRecent: 0,
+		},
+	}
+
+	state := &followState{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, err := e.newFollows(e.sessions[0], state, tt.now)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if total != tt.expectedTotal || state.new != tt.expectedNew || len(state.recent) != tt.expectedRecent {
+				t.Errorf("expected %v total, %v new and %v recent, got %v, %v and %v", tt.expectedTotal, tt.expectedNew, tt.expectedRecent, total, state.new, len(state.recent))
+			}
+		})
+	}
+
+	// Pages stop at the cursor
+	if calls := fake.callCount(followersPath); calls != 4 {
+		t.Errorf("expected 4 followers requests, got %v", calls)
+	}
+}
+
+func TestFollowersCollectorMissingScope(t *testing.T) {
+	e, _ := newTestExporter(t, map[string][]fakeResponse{
+		usersPath:     {{200, `{"data":[{"id":"1","login":"cool4pso"}]}`}},
+		followersPath: {{200, `{"total":42,"data":[]}`}},
+	}, &Settings{
+		UserToken:  true,
+		Users:      []TwitchUser{{Name: "cool4pso", AccessToken: "token"}},
+		Collectors: map[string]bool{"followers": true},
+	})
+	e.setGrantedScopes(e.sessions[0], []string{"channel:read:subscriptions"})
+
+	metrics, err := updateMetrics(t, e.collectors["followers"], &scrape{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if got := metrics["twitch_followers_total{cool4pso}"]; got != 42 {
+		t.Errorf("expected 42 followers, got %v", metrics)
+	}
+
+	if _, ok := metrics["twitch_followers_new_total{cool4pso}"]; ok {
+		t.Errorf("expected no new followers metric without the %v scope", followerListScope)
+	}
+}
@@ -0,0 +1,316 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	helix "github.com/nicklaw5/helix/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector(collectorInfo{
+		name:           "moderation",
+		scope:          userScope,
+		defaultEnabled: false,
+		scopes: []string{
+			"channel:read:vips",
+			"moderation:read",
+			"moderator:read:blocked_terms",
+			"moderator:read:shield_mode",
+		},
+		optionalScopes: []string{automodScope},
+		factory:        newModerationCollector,
+	})
+}
+
+type bannedUser struct {
+	UserID    string `json:"user_id"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type shieldModeStatus struct {
+	IsActive        bool   `json:"is_active"`
+	LastActivatedAt string `json:"last_activated_at"`
+}
+
+// Collects the moderation state of the authenticated users channels
+type moderationCollector struct {
+	e                    *Exporter
+	moderators           *prometheus.Desc
+	vips                 *prometheus.Desc
+	bannedUsers          *prometheus.Desc
+	blockedTerms         *prometheus.Desc
+	shieldModeActive     *prometheus.Desc
+	shieldModeActivation *prometheus.Desc
+	automodHeld          *prometheus.Desc
+	automodResolved      *prometheus.Desc
+	eventSubConnected    *prometheus.Desc
+
+	mu sync.Mutex
+	// AutoMod listeners by user
+	listeners map[string]*automodListener
+}
+
+func newModerationCollector(e *Exporter) collector {
+	return &moderationCollector{
+		e: e,
+		moderators: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "moderation", "moderators"),
+			"Number of channel moderators",
+			[]string{"name"}, nil,
+		),
+		vips: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "moderation", "vips"),
+			"Number of channel VIPs",
+			[]string{"name"}, nil,
+		),
+		bannedUsers: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "moderation", "banned_users"),
+			"Number of users currently banned or timed out from the channel",
+			[]string{"name", "type"}, nil,
+		),
+		blockedTerms: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "moderation", "blocked_terms"),
+			"Number of terms blocked in the channel chat",
+			[]string{"name"}, nil,
+		),
+		shieldModeActive: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "moderation", "shield_mode_active"),
+			"If shield mode is active in the channel",
+			[]string{"name"}, nil,
+		),
+		shieldModeActivation: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "moderation", "shield_mode_last_activated_timestamp_seconds"),
+			"Unix timestamp at which shield mode was last activated",
+			[]string{"name"}, nil,
+		),
+		automodHeld: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "moderation", "automod_held_messages_total"),
+			"Number of chat messages held for review by AutoMod or a blocked term since the exporter started",
+			[]string{"name", "reason"}, nil,
+		),
+		automodResolved: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "moderation", "automod_resolved_messages_total"),
+			"Number of held chat messages approved, denied or expired since the exporter started",
+			[]string{"name", "status"}, nil,
+		),
+		eventSubConnected: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "moderation", "eventsub_connected"),
+			"If the EventSub connection receiving the AutoMod events is up",
+			[]string{"name"}, nil,
+		),
+		listeners: make(map[string]*automodListener),
+	}
+}
+
+func (c *moderationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.moderators
+	ch <- c.vips
+	ch <- c.bannedUsers
+	ch <- c.blockedTerms
+	ch <- c.shieldModeActive
+	ch <- c.shieldModeActivation
+	ch <- c.automodHeld
+	ch <- c.automodResolved
+	ch <- c.eventSubConnected
+}
+
+func (c *moderationCollector) Update(s *scrape, ch chan<- prometheus.Metric) error {
+	var errs []error
+	for _, u := range c.e.sessionsFor("moderation") {
+		if err := c.updateUser(u, ch); err != nil {
+			errs = append(errs, err)
+		}
+
+		c.updateAutomod(u, ch)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Sends the metrics of the user, a failing request does not prevent the
+// metrics of the other requests
+func (c *moderationCollector) updateUser(u *userSession, ch chan<- prometheus.Metric) error {
+	userID, err := c.e.getUserID(u)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if count, err := c.e.moderatorCount(u, userID); err != nil {
+		errs = append(errs, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.moderators, prometheus.GaugeValue, float64(count), u.name)
+	}
+
+	if count, err := c.e.vipCount(u, userID); err != nil {
+		errs = append(errs, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.vips, prometheus.GaugeValue, float64(count), u.name)
+	}
+
+	if bans, timeouts, err := c.e.bannedUserCount(u, userID); err != nil {
+		errs = append(errs, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.bannedUsers, prometheus.GaugeValue, float64(bans), u.name, "ban")
+		ch <- prometheus.MustNewConstMetric(c.bannedUsers, prometheus.GaugeValue, float64(timeouts), u.name, "timeout")
+	}
+
+	if count, err := c.e.blockedTermCount(u, userID); err != nil {
+		errs = append(errs, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.blockedTerms, prometheus.GaugeValue, float64(count), u.name)
+	}
+
+	if status, err := c.e.shieldMode(u, userID); err != nil {
+		errs = append(errs, err)
+	} else {
+		active := 0
+		if status.IsActive {
+			active = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.shieldModeActive, prometheus.GaugeValue, float64(active), u.name)
+
+		if activatedAt, err := time.Parse(time.RFC3339, status.LastActivatedAt); err == nil {
+			ch <- prometheus.MustNewConstMetric(c.shieldModeActivation, prometheus.GaugeValue, float64(activatedAt.Unix()), u.name)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Starts the AutoMod listener of the user if it is not running and sends its
+// counts. Without the AutoMod scope there are no AutoMod metrics.
+func (c *moderationCollector) updateAutomod(u *userSession, ch chan<- prometheus.Metric) {
+	if c.e.missingScope(u, automodScope) {
+		c.e.Logger.Debug("Not collecting AutoMod messages, user token is missing scopes", "user", u.name, "missingScopes", []string{automodScope})
+		return
+	}
+
+	c.mu.Lock()
+	l, ok := c.listeners[u.name]
+	if !ok {
+		l = newAutomodListener()
+		c.listeners[u.name] = l
+	}
+	c.mu.Unlock()
+
+	if l.start() {
+		go c.e.listenAutomod(u, l)
+	}
+
+	counts := l.counts()
+	ch <- prometheus.MustNewConstMetric(c.eventSubConnected, prometheus.GaugeValue, boolValue(counts.connected), u.name)
+	for reason, count := range counts.held {
+		ch <- prometheus.MustNewConstMetric(c.automodHeld, prometheus.CounterValue, float64(count), u.name, reason)
+	}
+	for status, count := range counts.resolved {
+		ch <- prometheus.MustNewConstMetric(c.automodResolved, prometheus.CounterValue, float64(count), u.name, status)
+	}
+}
+
+func (e *Exporter) moderatorCount(u *userSession, userID string) (int, error) {
+	e.Logger.Debug("getting moderators", "user", u.name)
+	params := &helix.GetModeratorsParams{BroadcasterID: userID, First: 100}
+	count := 0
+	for {
+		resp, err := u.client.GetModerators(params)
+		if err != nil {
+			return 0, fmt.Errorf("Failed to get %v moderators: %w", u.name, err)
+		}
+
+		if resp.StatusCode != 200 {
+			return 0, fmt.Errorf("Failed to get %v moderators, status code %v: %v", u.name, resp.StatusCode, resp.ErrorMessage)
+		}
+
+		count += len(resp.Data.Moderators)
+		if resp.Data.Pagination.Cursor == "" || len(resp.Data.Moderators) == 0 {
+			return count, nil
+		}
+		params.After = resp.Data.Pagination.Cursor
+	}
+}
+
+func (e *Exporter) vipCount(u *userSession, userID string) (int, error) {
+	e.Logger.Debug("getting VIPs", "user", u.name)
+	params := &helix.GetChannelVipsParams{BroadcasterID: userID, First: 100}
+	count := 0
+	for {
+		resp, err := u.client.GetChannelVips(params)
+		if err != nil {
+			return 0, fmt.Errorf("Failed to get %v VIPs: %w", u.name, err)
+		}
+
+		if resp.StatusCode != 200 {
+			return 0, fmt.Errorf("Failed to get %v VIPs, status code %v: %v", u.name, resp.StatusCode, resp.ErrorMessage)
+		}
+
+		count += len(resp.Data.ChannelsVips)
+		if resp.Data.Pagination.Cursor == "" || len(resp.Data.ChannelsVips) == 0 {
+			return count, nil
+		}
+		params.After = resp.Data.Pagination.Cursor
+	}
+}
+
+// Returns the number of banned and timed out users. The helix client pages
+// the banned users by 20, they are requested without it by 100.
+func (e *Exporter) bannedUserCount(u *userSession, userID string) (int, int, error) {
+	e.Logger.Debug("getting banned users", "user", u.name)
+	banned, err := getAPI[bannedUser](e, u.client, "/moderation/banned", url.Values{"broadcaster_id": {userID}, "first": {"100"}})
+	if err != nil {
+		return 0, 0, fmt.Errorf("Failed to get %v banned users: %w", u.name, err)
+	}
+
+	var bans, timeouts int
+	for _, b := range banned {
+		if b.ExpiresAt == "" {
+			bans++
+		} else {
+			timeouts++
+		}
+	}
+
+	return bans, timeouts, nil
+}
+
+func (e *Exporter) blockedTermCount(u *userSession, userID string) (int, error) {
+	e.Logger.Debug("getting blocked terms", "user", u.name)
+	params := &helix.BlockedTermsParams{BroadcasterID: userID, ModeratorID: userID, First: 100}
+	count := 0
+	for {
+		resp, err := u.client.GetBlockedTerms(params)
+		if err != nil {
+			return 0, fmt.Errorf("Failed to get %v blocked terms: %w", u.name, err)
+		}
+
+		if resp.StatusCode != 200 {
+			return 0, fmt.Errorf("Failed to get %v blocked terms, status code %v: %v", u.name, resp.StatusCode, resp.ErrorMessage)
+		}
+
+		count += len(resp.Data.Terms)
+		if resp.Data.Pagination.Cursor == "" || len(resp.Data.Terms) == 0 {
+			return count, nil
+		}
+		params.After = resp.Data.Pagination.Cursor
+	}
+}
+
+// Returns the shield mode status, the helix client has no shield mode endpoint
+func (e *Exporter) shieldMode(u *userSession, userID string) (shieldModeStatus, error) {
+	e.Logger.Debug("getting shield mode status", "user", u.name)
+	status, err := getAPI[shieldModeStatus](e, u.client, "/moderation/shield_mode", url.Values{"broadcaster_id": {userID}, "moderator_id": {userID}})
+	if err != nil {
+		return shieldModeStatus{}, fmt.Errorf("Failed to get %v shield mode status: %w", u.name, err)
+	}
+
+	if len(status) == 0 {
+		return shieldModeStatus{}, fmt.Errorf("Failed to get %v shield mode status: empty response", u.name)
+	}
+
+	return status[0], nil
+}
@@ -0,0 +1,51 @@
+package collectors
+
+import (
+	"testing"
+)
+
+func TestModerationCollector(t *testing.T) {
+	e, _ := newTestExporter(t, map[string][]fakeResponse{
+		usersPath: {{200, `{"data":[{"id":"1","login":"cool4pso"}]}`}},
+		"/helix/moderation/moderators": {
+			{200, `{"data":[{"user_id":"2"},{"user_id":"3"}],"pagination":{"cursor":"next"}}`},
+			{200, `{"data":[{"user_id":"4"}]}`},
+		},
+		"/helix/channels/vips": {{200, `{"data":[{"user_id":"5"}]}`}},
+		"/helix/moderation/banned": {
+			{200, `{"data":[{"user_id":"6","expires_at":""},{"user_id":"7","expires_at":"2026-10-18T12:00:00Z"}],"pagination":{"cursor":"next"}}`},
+			{200, `{"data":[{"user_id":"8","expires_at":""}]}`},
+		},
+		"/helix/moderation/blocked_terms": {{500, `{"message":"internal error"}`}},
+		"/helix/moderation/shield_mode":   {{200, `{"data":[{"is_active":true,"last_activated_at":"2026-10-18T10:00:00Z"}]}`}},
+	}, &Settings{
+		UserToken:  true,
+		Users:      []TwitchUser{{Name: "cool4pso", AccessToken: "token"}},
+		Collectors: map[string]bool{"moderation": true},
+	})
+	// AutoMod events are tested on their own
+	e.setGrantedScopes(e.sessions[0], collectorRegistry["moderation"].scopes)
+
+	metrics, err := updateMetrics(t, e.collectors["moderation"], &scrape{})
+	if err == nil {
+		t.Errorf("expected blocked terms error, got nil")
+	}
+
+	expected := map[string]float64{
+		"twitch_moderation_moderators{cool4pso}":                                   3,
+		"twitch_moderation_vips{cool4pso}":                                         1,
+		"twitch_moderation_banned_users{cool4pso,ban}":                             2,
+		"twitch_moderation_banned_users{cool4pso,timeout}":                         1,
+		"twitch_moderation_shield_mode_active{cool4pso}":                           1,
+		"twitch_moderation_shield_mode_last_activated_timestamp_seconds{cool4pso}": 1792317600,
+	}
+	for name, value := range expected {
+		if got, ok := metrics[name]; !ok || got != value {
+			t.Errorf("expected %v %v, got %v", name, value, metrics)
+		}
+	}
+
+	if _, ok := metrics["twitch_moderation_blocked_terms{cool4pso}"]; ok {
+		t.Errorf("expected no blocked terms metric after the request failed")
+	}
+}
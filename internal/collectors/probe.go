@@ -0,0 +1,104 @@
+package collectors
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Module used by probes that don't ask for one
+const DefaultProbeModule = "default"
+
+var loginRE = regexp.MustCompile(`^[a-zA-Z0-9_]{1,25}$`)
+
+// ProbeModule selects the collectors that run when probing a channel
+type ProbeModule struct {
+	Collectors []string
+}
+
+// Collects the metrics of a single channel, registered on a registry created
+// for each probe request. The collectors are created for the probe, probed
+// channels leave no state behind.
+type probeCollector struct {
+	e          *Exporter
+	s          *scrape
+	collectors map[string]collector
+	success    *prometheus.Desc
+	duration   *prometheus.Desc
+}
+
+// Probe returns a collector for the metrics of the target channel, running
+// the collectors of the module. Without a default module configured, the
+// default module runs every enabled channel collector.
+func (e *Exporter) Probe(target, module string) (prometheus.Collector, error) {
+	if !loginRE.MatchString(target) {
+		return nil, fmt.Errorf("Invalid target %q, expected a twitch channel login", target)
+	}
+
+	if module == "" {
+		module = DefaultProbeModule
+	}
+
+	e.configMu.RLock()
+	m, ok := e.Settings.Modules[module]
+	e.configMu.RUnlock()
+
+	collectors := m.Collectors
+	switch {
+	case !ok && module != DefaultProbeModule:
+		return nil, fmt.Errorf("Unknown module %q", module)
+	case !ok:
+		for _, name := range ChannelCollectors() {
+			if e.collectorEnabled(name) {
+				collectors = append(collectors, name)
+			}
+		}
+	}
+
+	instances := make(map[string]collector)
+	for _, name := range collectors {
+		if info, ok := collectorRegistry[name]; ok {
+			instances[name] = info.factory(e)
+		}
+	}
+
+	return &probeCollector{
+		e:          e,
+		s:          &scrape{channels: []TwitchChannel{{Name: target, Collectors: collectors}}, probe: true},
+		collectors: instances,
+		success: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "probe", "success"),
+			"If every collector of the probe succeeded",
+			nil, nil,
+		),
+		duration: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "probe", "duration_seconds"),
+			"Duration of the probe",
+			nil, nil,
+		),
+	}, nil
+}
+
+// Describes nothing, the collector is only used by the probe registry where
+// the metrics don't need to be checked in advance
+func (p *probeCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (p *probeCollector) Collect(ch chan<- prometheus.Metric) {
+	start := time.Now()
+	success := 1
+	for _, name := range ChannelCollectors() {
+		c, ok := p.collectors[name]
+		if !ok {
+			continue
+		}
+
+		if !p.e.runCollector(name, c, p.s, ch) {
+			success = 0
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(p.duration, prometheus.GaugeValue, time.Since(start).Seconds())
+	ch <- prometheus.MustNewConstMetric(p.success, prometheus.GaugeValue, float64(success))
+}
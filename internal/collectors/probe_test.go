@@ -0,0 +1,156 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	streamsPath  = "/helix/streams"
+	channelsPath = "/helix/channels"
+)
+
+func TestProbe(t *testing.T) {
+	live := map[string][]fakeResponse{
+		streamsPath:  {{200, `{"data":[{"user_id":"1","user_login":"cool4pso","type":"live","viewer_count":5}]}`}},
+		usersPath:    {{200, `{"data":[{"id":"1","login":"cool4pso"}]}`}},
+		channelsPath: {{200, `{"data":[{"broadcaster_id":"1","title":"hello"}]}`}},
+	}
+
+	tests := []struct {
+		name            string
+		target          string
+		module          string
+		responses       map[string][]fakeResponse
+		expectedErr     bool
+		expectedMetrics map[string]float64
+	}{
+		{
+			name:        "Invalid target",
+			target:      "cool4pso&module=x",
+			expectedErr: true,
+		},
+		{
+			name:        "Unknown module",
+			target:      "cool4pso",
+			module:      "unknown",
+			expectedErr: true,
+		},
+		{
+			name:      "Default module",
+			target:    "cool4pso",
+			responses: live,
+			expectedMetrics: map[string]float64{
+				"twitch_is_live":       1,
+				"twitch_viewer_total":  5,
+				"twitch_channel_info":  1,
+				"twitch_probe_success": 1,
+			},
+		},
+		{
+			name:      "Configured module",
+			target:    "cool4pso",
+			module:    "stream",
+			responses: live,
+			expectedMetrics: map[string]float64{
+				"twitch_is_live":       1,
+				"twitch_probe_success": 1,
+			},
+		},
+		{
+			name:      "Failed probe",
+			target:    "cool4pso",
+			responses: map[string][]fakeResponse{usersPath: {{500, ""}}},
+			expectedMetrics: map[string]float64{
+				"twitch_probe_success": 0,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestExporter(t, tt.responses, &Settings{
+				Modules: map[string]ProbeModule{"stream": {Collectors: []string{"stream"}}},
+			})
+
+			probe, err := e.Probe(tt.target, tt.module)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error: %v, got: %v", tt.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(probe)
+			families, err := reg.Gather()
+			if err != nil {
+				t.Fatalf("failed to gather metrics: %v", err)
+			}
+
+			metrics := make(map[string]float64)
+			for _, f := range families {
+				metrics[f.GetName()] = f.GetMetric()[0].GetGauge().GetValue()
+			}
+
+			for name, value := range tt.expectedMetrics {
+				if got, ok := metrics[name]; !ok || got != value {
+					t.Errorf("expected %v %v, got %v", name, value, got)
+				}
+			}
+		})
+	}
+}
+
+func TestProbeState(t *testing.T) {
+	e, fake := newTestExporter(t, map[string][]fakeResponse{
+		usersPath:    {{200, `{"data":[{"id":"1","login":"cool4pso"}]}`}},
+		channelsPath: {{200, `{"data":[{"broadcaster_id":"1","title":"hello"}]}`}},
+		clipsPath:    {{200, `{"data":[]}`}},
+		videosPath:   {{200, `{"data":[]}`}},
+	}, &Settings{
+		Modules: map[string]ProbeModule{"state": {Collectors: []string{"channel", "content"}}},
+	})
+
+	for range 2 {
+		probe, err := e.Probe("cool4pso", "state")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(probe)
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("failed to gather metrics: %v", err)
+		}
+
+		names := make(map[string]bool)
+		for _, f := range families {
+			names[f.GetName()] = true
+		}
+
+		if !names["twitch_channel_info"] || !names["twitch_clips_top_views"] {
+			t.Errorf("expected channel and content metrics, got %v", names)
+		}
+
+		// Counted since the exporter started, probes have nothing to count from
+		for _, name := range []string{"twitch_clips_created_total", "twitch_channel_title_changes_total"} {
+			if names[name] {
+				t.Errorf("expected no %v metric", name)
+			}
+		}
+	}
+
+	content := e.collectors["content"].(*contentCollector)
+	info := e.collectors["channel"].(*channelInfoCollector)
+	if len(content.states) != 0 || len(info.titles) != 0 || len(e.idCache) != 0 {
+		t.Errorf("expected no state for the probed channel, got %v, %v and %v", content.states, info.titles, e.idCache)
+	}
+
+	// Every probe reads the content again
+	if calls := fake.callCount(clipsPath); calls < 2 {
+		t.Errorf("expected the clips requested by every probe, got %v requests", calls)
+	}
+}
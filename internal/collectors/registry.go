@@ -0,0 +1,291 @@
+package collectors
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector collects the metrics of a group of twitch api features. Each
+// collector runs on its own during a scrape, a failing collector does not
+// affect the others.
+type collector interface {
+	Describe(ch chan<- *prometheus.Desc)
+	// Update sends the metrics of the scrape, errors mark the collector as
+	// failed but the metrics already sent are still exported
+	Update(s *scrape, ch chan<- prometheus.Metric) error
+}
+
+// Implemented by the collectors keeping state per channel across scrapes,
+// they forget the channels the scrape no longer collects them for
+type channelPruner interface {
+	pruneChannels(s *scrape)
+}
+
+type collectorScope int
+
+const (
+	// Collected for every channel
+	channelScope collectorScope = iota
+	// Collected for every authenticated user, requires a user token
+	userScope
+	// Collected once, independent of the channels and users
+	globalScope
+)
+
+type collectorInfo struct {
+	name           string
+	scope          collectorScope
+	defaultEnabled bool
+	// Twitch scopes the user token needs for user collectors
+	scopes []string
+	// Twitch scopes requested for some of the metrics of user collectors,
+	// the collector still runs without them
+	optionalScopes []string
+	// Labels the channel collectors add to their metrics after the channel
+	// name, custom channel labels can't use them
+	labels  []string
+	factory func(e *Exporter) collector
+}
+
+var collectorRegistry = make(map[string]collectorInfo)
+
+func registerCollector(info collectorInfo) {
+	if _, ok := collectorRegistry[info.name]; ok {
+		panic(fmt.Sprintf("collector %v registered more than once", info.name))
+	}
+
+	collectorRegistry[info.name] = info
+}
+
+func collectorNames(filter func(info collectorInfo) bool) []string {
+	var names []string
+	for name, info := range collectorRegistry {
+		if filter(info) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Collectors returns the names of every collector
+func Collectors() []string {
+	return collectorNames(func(collectorInfo) bool { return true })
+}
+
+// ChannelCollectors returns the names of the collectors that can be enabled per channel
+func ChannelCollectors() []string {
+	return collectorNames(func(info collectorInfo) bool { return info.scope == channelScope })
+}
+
+// UserCollectors returns the names of the collectors that need a user token
+func UserCollectors() []string {
+	return collectorNames(func(info collectorInfo) bool { return info.scope == userScope })
+}
+
+// CollectorDefault returns if the collector is enabled when not configured
+func CollectorDefault(name string) bool {
+	return collectorRegistry[name].defaultEnabled
+}
+
+// ReservedLabels returns the label names of the channel metrics, the channel
+// name and the labels added by the channel collectors
+func ReservedLabels() []string {
+	labels := []string{"name"}
+	for _, info := range collectorRegistry {
+		for _, label := range info.labels {
+			if !slices.Contains(labels, label) {
+				labels = append(labels, label)
+			}
+		}
+	}
+	sort.Strings(labels)
+
+	return labels
+}
+
+// State of a scrape shared by the collectors, settings changed by reloads are
+// read once per scrape
+type scrape struct {
+	channels   []TwitchChannel
+	labelNames []string
+	// Probes run on collectors of their own, metrics counting since the
+	// exporter started are not exported
+	probe bool
+}
+
+// Returns the channels the collector is enabled for
+func (s *scrape) channelsFor(name string) []*TwitchChannel {
+	var channels []*TwitchChannel
+	for i := range s.channels {
+		if s.channels[i].collectorEnabled(name) {
+			channels = append(channels, &s.channels[i])
+		}
+	}
+
+	return channels
+}
+
+// Returns the names of the channels the collector runs for
+func (s *scrape) channelNamesFor(name string) map[string]bool {
+	names := make(map[string]bool)
+	for _, c := range s.channelsFor(name) {
+		names[c.Name] = true
+	}
+
+	return names
+}
+
+// Forgets the cached results and the collectors state of the channels no
+// longer scraped, after reloads and discoveries
+func (e *Exporter) pruneChannels() {
+	e.configMu.RLock()
+	s := &scrape{channels: e.scrapeChannels()}
+	e.configMu.RUnlock()
+
+	keep := make(map[string]bool)
+	for _, c := range s.channels {
+		keep[c.Name] = true
+	}
+
+	e.cacheMu.Lock()
+	for name := range e.channelCache {
+		if !keep[name] {
+			delete(e.channelCache, name)
+		}
+	}
+	e.cacheMu.Unlock()
+
+	for _, c := range e.collectors {
+		if p, ok := c.(channelPruner); ok {
+			p.pruneChannels(s)
+		}
+	}
+}
+
+// Returns the authorized users with the scopes the collector needs
+func (e *Exporter) sessionsFor(name string) []*userSession {
+	if !e.collectUserMetrics() {
+		return nil
+	}
+
+	var sessions []*userSession
+	for _, u := range e.sessions {
+		if u.client.GetUserAccessToken() == "" {
+			e.Logger.Debug(errAuthorizationPending.Error(), "user", u.name, "collector", name)
+			continue
+		}
+
+		if e.canCollect(u, name) {
+			sessions = append(sessions, u)
+		}
+	}
+
+	return sessions
+}
+
+// Sends a channel metric with the channel name, extra label values and the
+// custom channel labels
+func (s *scrape) channelMetric(ch chan<- prometheus.Metric, d *channelDesc, valueType prometheus.ValueType, value float64, c *TwitchChannel, labelValues ...string) {
+	values := append([]string{c.Name}, labelValues...)
+	for _, name := range s.labelNames {
+		values = append(values, c.Labels[name])
+	}
+
+	ch <- prometheus.MustNewConstMetric(d.desc(s.labelNames), valueType, value, values...)
+}
+
+// Description of a channel metric. Channel metrics carry the custom channel
+// labels, which can change on reload, the description is created again when
+// they do.
+type channelDesc struct {
+	fqName      string
+	help        string
+	extraLabels []string
+
+	mu         sync.Mutex
+	labelNames []string
+	current    *prometheus.Desc
+}
+
+func newChannelDesc(fqName, help string, extraLabels ...string) *channelDesc {
+	return &channelDesc{fqName: fqName, help: help, extraLabels: extraLabels}
+}
+
+func (d *channelDesc) desc(labelNames []string) *prometheus.Desc {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.current == nil || !slices.Equal(d.labelNames, labelNames) {
+		variableLabels := append([]string{"name"}, d.extraLabels...)
+		variableLabels = append(variableLabels, labelNames...)
+		d.current = prometheus.NewDesc(d.fqName, d.help, variableLabels, nil)
+		d.labelNames = slices.Clone(labelNames)
+	}
+
+	return d.current
+}
+
+func newCollectors(e *Exporter) map[string]collector {
+	collectors := make(map[string]collector)
+	for name, info := range collectorRegistry {
+		collectors[name] = info.factory(e)
+	}
+
+	return collectors
+}
+
+// Runs the enabled collectors concurrently, recording the duration and result
+// of each one
+func (e *Exporter) runCollectors(s *scrape, ch chan<- prometheus.Metric) {
+	var wg sync.WaitGroup
+	for _, name := range Collectors() {
+		if !e.collectorEnabled(name) {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.runCollector(name, e.collectors[name], s, ch)
+		}()
+	}
+	wg.Wait()
+}
+
+// Returns true if the collector succeeded
+func (e *Exporter) runCollector(name string, c collector, s *scrape, ch chan<- prometheus.Metric) bool {
+	start := time.Now()
+	err := updateCollector(c, s, ch)
+	duration := time.Since(start)
+
+	success := 1
+	if err != nil {
+		success = 0
+		e.Logger.Error("Collector failed", "collector", name, "duration", duration, "err", err)
+	} else {
+		e.Logger.Debug("Collector succeeded", "collector", name, "duration", duration)
+	}
+
+	ch <- prometheus.MustNewConstMetric(e.metrics.collectorDuration, prometheus.GaugeValue, duration.Seconds(), name)
+	ch <- prometheus.MustNewConstMetric(e.metrics.collectorSuccess, prometheus.GaugeValue, float64(success), name)
+
+	return err == nil
+}
+
+// Updates the collector, a panic fails the collector instead of the exporter
+func updateCollector(c collector, s *scrape, ch chan<- prometheus.Metric) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Collector panicked: %v", r)
+		}
+	}()
+
+	return c.Update(s, ch)
+}
@@ -0,0 +1,145 @@
+package collectors
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+type fakeCollector struct {
+	desc   *prometheus.Desc
+	update func() error
+}
+
+func (c *fakeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *fakeCollector) Update(s *scrape, ch chan<- prometheus.Metric) error {
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1)
+	return c.update()
+}
+
+func TestRunCollectors(t *testing.T) {
+	// Only the fake collectors run
+	disabled := map[string]bool{"subscriptions": false}
+	for _, name := range Collectors() {
+		if name != "stream" && name != "followers" {
+			disabled[name] = false
+		}
+	}
+
+	e, _ := newTestExporter(t, nil, &Settings{Collectors: disabled})
+	newFake := func(name string, update func() error) *fakeCollector {
+		return &fakeCollector{desc: prometheus.NewDesc("fake_"+name, "fake", nil, nil), update: update}
+	}
+	e.collectors = map[string]collector{
+		"stream":        newFake("stream", func() error { panic("boom") }),
+		"followers":     newFake("followers", func() error { return nil }),
+		"subscriptions": newFake("subscriptions", func() error { return errors.New("disabled") }),
+	}
+
+	ch := make(chan prometheus.Metric, 100)
+	e.runCollectors(&scrape{}, ch)
+	close(ch)
+
+	success := make(map[string]float64)
+	var collected []string
+	for m := range ch {
+		desc := m.Desc().String()
+		if !strings.Contains(desc, "collector_success") {
+			if !strings.Contains(desc, "collector_duration_seconds") {
+				collected = append(collected, desc)
+			}
+			continue
+		}
+
+		var metric dto.Metric
+		_ = m.Write(&metric)
+		success[metric.GetLabel()[0].GetValue()] = metric.GetGauge().GetValue()
+	}
+
+	expected := map[string]float64{"stream": 0, "followers": 1}
+	if len(success) != len(expected) {
+		t.Fatalf("expected collectors %v to run, got %v", expected, success)
+	}
+
+	for name, value := range expected {
+		if success[name] != value {
+			t.Errorf("collector %v: expected success %v, got %v", name, value, success[name])
+		}
+	}
+
+	// Metrics sent before a collector fails are still exported
+	if len(collected) != 2 {
+		t.Errorf("expected metrics of the stream and followers collectors, got %v", collected)
+	}
+}
+
+func TestReservedLabels(t *testing.T) {
+	e, _ := newTestExporter(t, nil, &Settings{})
+	variableLabels := regexp.MustCompile(`variableLabels: \{([^}]*)\}`)
+
+	// Custom channel labels can't collide with the labels of any channel
+	// metric
+	for _, name := range ChannelCollectors() {
+		ch := make(chan *prometheus.Desc, 100)
+		e.collectors[name].Describe(ch)
+		close(ch)
+
+		for desc := range ch {
+			match := variableLabels.FindStringSubmatch(desc.String())
+			if match == nil || match[1] == "" {
+				continue
+			}
+
+			for _, label := range strings.Split(match[1], ",") {
+				if !slices.Contains(ReservedLabels(), label) {
+					t.Errorf("collector %v: label %v of %v is not reserved", name, label, desc)
+				}
+			}
+		}
+	}
+}
+
+func TestRequiredScopes(t *testing.T) {
+	tests := []struct {
+		name       string
+		collectors map[string]bool
+		expected   []string
+		missing    []string
+	}{
+		{
+			name:       "Optional scopes of the enabled collectors",
+			collectors: map[string]bool{"followers": true, "moderation": true},
+			expected:   []string{followerListScope, automodScope, "moderation:read"},
+		},
+		{
+			name:       "Disabled collectors",
+			collectors: map[string]bool{"followers": false, "moderation": false},
+			missing:    []string{followerListScope, automodScope, "moderation:read"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scopes := (&Settings{Collectors: tt.collectors}).RequiredScopes()
+			for _, scope := range tt.expected {
+				if !slices.Contains(scopes, scope) {
+					t.Errorf("expected scope %v, got %v", scope, scopes)
+				}
+			}
+
+			for _, scope := range tt.missing {
+				if slices.Contains(scopes, scope) {
+					t.Errorf("expected no scope %v, got %v", scope, scopes)
+				}
+			}
+		})
+	}
+}
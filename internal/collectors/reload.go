@@ -0,0 +1,116 @@
+package collectors
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Reload reads the settings again with LoadSettings and applies the channels,
+// collectors, probe modules, discovery, categories, content and viewers
+// settings. Channels that did not change keep their cached results and
+// collector state, users and their tokens are kept as they are.
+func (e *Exporter) Reload() error {
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+
+	if e.LoadSettings == nil {
+		return fmt.Errorf("Reloading settings not supported")
+	}
+
+	s, err := e.LoadSettings()
+	if err != nil {
+		e.setReloadResult(false)
+		e.Logger.Error("Failed to reload settings", "err", err)
+		return err
+	}
+
+	e.applySettings(s)
+	e.tokenMu.Lock()
+	e.readSecretFiles()
+	e.tokenMu.Unlock()
+	e.setReloadResult(true)
+	e.Logger.Info("Settings reloaded")
+
+	return nil
+}
+
+func (e *Exporter) setReloadResult(success bool) {
+	e.configMu.Lock()
+	defer e.configMu.Unlock()
+
+	e.lastReloadSuccessful = success
+}
+
+func channelEqual(a, b TwitchChannel) bool {
+	return maps.Equal(a.Labels, b.Labels) &&
+		slices.Equal(a.Collectors, b.Collectors) &&
+		a.RefreshInterval == b.RefreshInterval
+}
+
+func (e *Exporter) applySettings(s *Settings) {
+	e.configMu.Lock()
+
+	current := make(map[string]TwitchChannel)
+	for _, c := range e.Settings.Channels {
+		current[c.Name] = c
+	}
+
+	e.cacheMu.Lock()
+	for _, c := range s.Channels {
+		old, ok := current[c.Name]
+		switch {
+		case !ok:
+			e.Logger.Info("Channel added", "channelName", c.Name)
+		case !channelEqual(old, c):
+			e.Logger.Info("Channel changed", "channelName", c.Name)
+			delete(e.channelCache, c.Name)
+		}
+		delete(current, c.Name)
+	}
+
+	for name := range current {
+		e.Logger.Info("Channel removed", "channelName", name)
+	}
+	e.cacheMu.Unlock()
+
+	e.labelNames = channelLabelNames(s.Channels)
+
+	e.Settings.Channels = s.Channels
+	e.Settings.Collectors = s.Collectors
+	e.Settings.Modules = s.Modules
+	e.Settings.Discovery = s.Discovery
+	e.Settings.Categories = s.Categories
+	e.Settings.Content = s.Content
+	e.Settings.Viewers = s.Viewers
+	if s.Discovery == nil {
+		e.discovered = nil
+		e.discoveredCounts = nil
+	}
+	e.configMu.Unlock()
+	e.pruneChannels()
+	e.triggerDiscovery()
+
+	var users []string
+	for _, u := range s.Users {
+		users = append(users, u.Name)
+	}
+	if !slices.Equal(users, e.sessionNames()) {
+		e.Logger.Warn("Changes to the authenticated users require a restart, keeping the current users", "users", e.sessionNames())
+	}
+
+	// The enabled collectors define the scopes to ask users for
+	for _, u := range e.sessions {
+		e.setAuthorizationURL(u)
+		e.logMissingScopes(u)
+	}
+}
+
+func (e *Exporter) sessionNames() []string {
+	var names []string
+	for _, u := range e.sessions {
+		names = append(names, u.name)
+	}
+
+	return names
+}
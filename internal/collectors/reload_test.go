@@ -0,0 +1,142 @@
+package collectors
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	e, _ := newTestExporter(t, nil, &Settings{
+		Channels: []TwitchChannel{
+			{Name: "kept"},
+			{Name: "changed", RefreshInterval: time.Minute},
+			{Name: "removed"},
+		},
+	})
+	for _, c := range e.Settings.Channels {
+		e.channelCache[c.Name] = channelState{refreshedAt: time.Now()}
+	}
+
+	e.LoadSettings = func() (*Settings, error) {
+		return &Settings{
+			Channels: []TwitchChannel{
+				{Name: "kept"},
+				{Name: "changed", RefreshInterval: 2 * time.Minute},
+				{Name: "added", Labels: map[string]string{"team": "coolapso"}},
+			},
+			Collectors: map[string]bool{"followers": false},
+		}, nil
+	}
+
+	if err := e.Reload(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for name, cached := range map[string]bool{"kept": true, "changed": false, "removed": false, "added": false} {
+		if _, ok := e.channelCache[name]; ok != cached {
+			t.Errorf("channel %v: expected cached %v, got %v", name, cached, ok)
+		}
+	}
+
+	if !slices.Equal(e.labelNames, []string{"team"}) {
+		t.Errorf("expected label names [team], got %v", e.labelNames)
+	}
+
+	if e.collectorEnabled("followers") {
+		t.Errorf("expected followers collector to be disabled")
+	}
+
+	if !e.lastReloadSuccessful {
+		t.Errorf("expected last reload to be successful")
+	}
+
+	e.LoadSettings = func() (*Settings, error) {
+		return nil, errors.New("invalid configuration")
+	}
+
+	if err := e.Reload(); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	if e.lastReloadSuccessful {
+		t.Errorf("expected last reload to be unsuccessful")
+	}
+
+	if len(e.Settings.Channels) != 3 || e.Settings.Channels[2].Name != "added" {
+		t.Errorf("expected failed reload to keep the channels, got %v", e.Settings.Channels)
+	}
+}
+
+func TestPruneChannels(t *testing.T) {
+	e, _ := newTestExporter(t, map[string][]fakeResponse{
+		teamsPath: {{200, `{"data":[{"users":[]}]}`}},
+	}, &Settings{
+		Channels: []TwitchChannel{
+			{Name: "kept"},
+			{Name: "stream_only", Collectors: []string{"stream"}},
+			{Name: "removed"},
+		},
+	})
+	e.discovered = []TwitchChannel{{Name: "discovered"}}
+
+	// Every collector has state for every channel, as probes leave it
+	stream := e.collectors["stream"].(*streamCollector)
+	content := e.collectors["content"].(*contentCollector)
+	info := e.collectors["channel"].(*channelInfoCollector)
+	for _, name := range []string{"kept", "stream_only", "removed", "discovered"} {
+		e.channelCache[name] = channelState{refreshedAt: time.Now()}
+		stream.windows[name] = &viewerWindow{}
+		content.states[name] = &contentState{}
+		info.titles[name] = "title"
+		info.titleChanged[name] = 1
+	}
+
+	// Returns the channels with state in every collector, and the ones with
+	// stream state only
+	kept := func() ([]string, []string) {
+		var all, streamOnly []string
+		for name := range e.channelCache {
+			_, window := stream.windows[name]
+			_, state := content.states[name]
+			_, title := info.titles[name]
+			_, changed := info.titleChanged[name]
+			switch {
+			case window && state && title && changed:
+				all = append(all, name)
+			case window && !state && !title && !changed:
+				streamOnly = append(streamOnly, name)
+			}
+		}
+		slices.Sort(all)
+
+		return all, streamOnly
+	}
+
+	e.LoadSettings = func() (*Settings, error) {
+		return &Settings{
+			Channels: []TwitchChannel{
+				{Name: "kept"},
+				{Name: "stream_only", Collectors: []string{"stream"}},
+			},
+			Discovery: &Discovery{Teams: []string{"coolapso"}},
+		}, nil
+	}
+
+	if err := e.Reload(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if all, streamOnly := kept(); !slices.Equal(all, []string{"discovered", "kept"}) || !slices.Equal(streamOnly, []string{"stream_only"}) || len(e.channelCache) != 3 {
+		t.Errorf("expected state for discovered and kept, stream state for stream_only, got %v, %v and cache %v", all, streamOnly, e.channelCache)
+	}
+
+	// The channel is no longer discovered
+	e.discoverChannels(e.Settings.Discovery)
+	e.pruneChannels()
+
+	if all, _ := kept(); !slices.Equal(all, []string{"kept"}) || len(e.channelCache) != 2 {
+		t.Errorf("expected state for kept only, got %v and cache %v", all, e.channelCache)
+	}
+}
@@ -0,0 +1,54 @@
+package collectors
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// Retry policy used when requesting and refreshing tokens
+type retryPolicy struct {
+	attempts     int
+	initialDelay time.Duration
+	maxDelay     time.Duration
+}
+
+var defaultRetryPolicy = retryPolicy{
+	attempts:     4,
+	initialDelay: time.Second,
+	maxDelay:     30 * time.Second,
+}
+
+// Returns the exponential backoff delay before the given retry, with full
+// jitter so multiple exporters don't hammer twitch at the same time
+func (p retryPolicy) delay(retry int) time.Duration {
+	d := p.initialDelay << retry
+	if d <= 0 || d > p.maxDelay {
+		d = p.maxDelay
+	}
+
+	return time.Duration(rand.Int64N(int64(d) + 1))
+}
+
+// Calls fn until it succeeds, returns a non retryable error, or the attempts
+// run out. Returns the last error.
+func (p retryPolicy) do(fn func() error) error {
+	var err error
+	for attempt := 0; attempt < max(p.attempts, 1); attempt++ {
+		if attempt > 0 {
+			time.Sleep(p.delay(attempt - 1))
+		}
+
+		err = fn()
+		if err == nil {
+			return nil
+		}
+
+		var tokenErr *TokenError
+		if errors.As(err, &tokenErr) && !tokenErr.Retryable() {
+			return err
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,131 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	helix "github.com/nicklaw5/helix/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Segments are requested from this long ago, to keep exporting a segment
+// whose start passed until it ends
+const scheduleWindow = 24 * time.Hour
+
+func init() {
+	registerCollector(collectorInfo{
+		name:           "schedule",
+		scope:          channelScope,
+		defaultEnabled: false,
+		labels:         []string{"category"},
+		factory:        newScheduleCollector,
+	})
+}
+
+// Collects the next stream of the channels schedule
+type scheduleCollector struct {
+	e         *Exporter
+	nextStart *channelDesc
+	canceled  *channelDesc
+}
+
+func newScheduleCollector(e *Exporter) collector {
+	return &scheduleCollector{
+		e: e,
+		nextStart: newChannelDesc(
+			prometheus.BuildFQName(namespace, "schedule", "next_start_timestamp_seconds"),
+			"Unix timestamp at which the scheduled stream in progress or the next one starts",
+			"category",
+		),
+		canceled: newChannelDesc(
+			prometheus.BuildFQName(namespace, "schedule", "next_canceled"),
+			"If the scheduled stream in progress or the next one is canceled",
+			"category",
+		),
+	}
+}
+
+func (c *scheduleCollector) Describe(ch chan<- *prometheus.Desc) {
+	labelNames := c.e.currentLabelNames()
+	ch <- c.nextStart.desc(labelNames)
+	ch <- c.canceled.desc(labelNames)
+}
+
+func (c *scheduleCollector) Update(s *scrape, ch chan<- prometheus.Metric) error {
+	channels := s.channelsFor("schedule")
+	var names []string
+	for _, twitchChannel := range channels {
+		names = append(names, twitchChannel.Name)
+	}
+
+	var errs []error
+	ids, err := c.e.channelIDs(names, !s.probe)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, twitchChannel := range channels {
+		id, ok := ids[strings.ToLower(twitchChannel.Name)]
+		if !ok {
+			if err == nil {
+				errs = append(errs, fmt.Errorf("Could not find channel %v", twitchChannel.Name))
+			}
+			continue
+		}
+
+		segment, err := c.e.nextSegment(twitchChannel.Name, id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if segment == nil {
+			continue
+		}
+
+		canceled := 0
+		if segment.CanceledUntil != "" {
+			canceled = 1
+		}
+
+		s.channelMetric(ch, c.nextStart, prometheus.GaugeValue, float64(segment.StartTime.Unix()), twitchChannel, segment.Category.Name)
+		s.channelMetric(ch, c.canceled, prometheus.GaugeValue, float64(canceled), twitchChannel, segment.Category.Name)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Returns the schedule segment in progress or the next one, nil when the
+// channel has no schedule
+func (e *Exporter) nextSegment(name, id string) (*helix.GetScheduleSegment, error) {
+	e.Logger.Debug("getting channel schedule", "channelName", name)
+	now := time.Now().UTC().Truncate(time.Second)
+	resp, err := e.client.GetSchedule(&helix.GetScheduleParams{
+		BroadcasterID: id,
+		StartTime:     helix.Time{Time: now.Add(-scheduleWindow)},
+		First:         25,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get channel %v schedule: %w", name, err)
+	}
+
+	// Channels without a schedule are not found
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Failed to get channel %v schedule, status code %v: %v", name, resp.StatusCode, resp.ErrorMessage)
+	}
+
+	for _, segment := range resp.Data.Schedule.Segments {
+		if segment.EndTime.After(now) || (segment.EndTime.IsZero() && segment.StartTime.After(now)) {
+			return &segment, nil
+		}
+	}
+
+	return nil, nil
+}
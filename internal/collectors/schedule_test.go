@@ -0,0 +1,79 @@
+package collectors
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNextSegment(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	segment := func(start, end time.Duration, canceled, category string) string {
+		return fmt.Sprintf(`{"start_time":%q,"end_time":%q,"canceled_until":%q,"category":{"name":%q}}`,
+			now.Add(start).Format(time.RFC3339), now.Add(end).Format(time.RFC3339), canceled, category)
+	}
+
+	tests := []struct {
+		name             string
+		response         fakeResponse
+		expectedStart    time.Time
+		expectedCategory string
+		expectedNil      bool
+		expectedErr      bool
+	}{
+		{
+			name: "Segment in progress",
+			response: fakeResponse{200, `{"data":{"segments":[` +
+				segment(-3*time.Hour, -time.Hour, "", "Minecraft") + "," +
+				segment(-time.Hour, time.Hour, "", "Just Chatting") + "," +
+				segment(23*time.Hour, 25*time.Hour, "", "Minecraft") + `]}}`},
+			expectedStart:    now.Add(-time.Hour),
+			expectedCategory: "Just Chatting",
+		},
+		{
+			name: "Next segment",
+			response: fakeResponse{200, `{"data":{"segments":[` +
+				segment(-3*time.Hour, -time.Hour, "", "Minecraft") + "," +
+				segment(2*time.Hour, 4*time.Hour, "2026-10-20T00:00:00Z", "Minecraft") + `]}}`},
+			expectedStart:    now.Add(2 * time.Hour),
+			expectedCategory: "Minecraft",
+		},
+		{
+			name:        "No schedule",
+			response:    fakeResponse{404, `{"status":404,"message":"segments were not found"}`},
+			expectedNil: true,
+		},
+		{
+			name:        "Server error",
+			response:    fakeResponse{500, ""},
+			expectedNil: true,
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestExporter(t, map[string][]fakeResponse{"/helix/schedule": {tt.response}}, &Settings{})
+
+			segment, err := e.nextSegment("cool4pso", "1")
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error: %v, got: %v", tt.expectedErr, err)
+			}
+
+			if tt.expectedNil {
+				if segment != nil {
+					t.Errorf("expected no segment, got %v", segment)
+				}
+				return
+			}
+
+			if segment == nil {
+				t.Fatalf("expected segment, got nil")
+			}
+
+			if !segment.StartTime.Equal(tt.expectedStart) || segment.Category.Name != tt.expectedCategory {
+				t.Errorf("expected segment %v %v, got %v %v", tt.expectedStart, tt.expectedCategory, segment.StartTime, segment.Category.Name)
+			}
+		})
+	}
+}
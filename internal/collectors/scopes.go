@@ -0,0 +1,113 @@
+package collectors
+
+import (
+	"slices"
+	"sort"
+)
+
+// CollectorEnabled reports if a collector is enabled, collectors not
+// configured use their default
+func (s *Settings) CollectorEnabled(name string) bool {
+	if enabled, ok := s.Collectors[name]; ok {
+		return enabled
+	}
+
+	return CollectorDefault(name)
+}
+
+// RequiredScopes returns the scopes to request during the authorization flow
+// based on the enabled collectors
+func (s *Settings) RequiredScopes() []string {
+	var scopes []string
+	for _, name := range UserCollectors() {
+		if !s.CollectorEnabled(name) {
+			continue
+		}
+
+		info := collectorRegistry[name]
+		for _, scope := range slices.Concat(info.scopes, info.optionalScopes) {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	if s.Discovery != nil && s.Discovery.Follows && !slices.Contains(scopes, followsDiscoveryScope) {
+		scopes = append(scopes, followsDiscoveryScope)
+	}
+
+	sort.Strings(scopes)
+
+	return scopes
+}
+
+func (e *Exporter) collectorEnabled(name string) bool {
+	e.configMu.RLock()
+	defer e.configMu.RUnlock()
+
+	return e.Settings.CollectorEnabled(name)
+}
+
+func (e *Exporter) requiredScopes() []string {
+	e.configMu.RLock()
+	defer e.configMu.RUnlock()
+
+	return e.Settings.RequiredScopes()
+}
+
+func (e *Exporter) setGrantedScopes(u *userSession, scopes []string) {
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+
+	u.grantedScopes = make(map[string]bool)
+	for _, scope := range scopes {
+		u.grantedScopes[scope] = true
+	}
+}
+
+// Returns the scopes required by the collector the user did not grant, scopes
+// are only known once the token was validated or obtained by the exporter
+func (e *Exporter) missingScopes(u *userSession, collector string) []string {
+	e.stateMu.RLock()
+	defer e.stateMu.RUnlock()
+
+	if u.grantedScopes == nil {
+		return nil
+	}
+
+	var missing []string
+	for _, scope := range collectorRegistry[collector].scopes {
+		if !u.grantedScopes[scope] {
+			missing = append(missing, scope)
+		}
+	}
+
+	return missing
+}
+
+func (e *Exporter) logMissingScopes(u *userSession) {
+	for _, name := range UserCollectors() {
+		if !e.collectorEnabled(name) {
+			continue
+		}
+
+		if missing := e.missingScopes(u, name); len(missing) > 0 {
+			e.Logger.Warn("User token is missing scopes, collector will not run", "user", u.name, "collector", name, "missingScopes", missing)
+		}
+	}
+}
+
+// Returns true if the user level collector is enabled and the user token has
+// all the scopes it needs
+func (e *Exporter) canCollect(u *userSession, collector string) bool {
+	if !e.collectorEnabled(collector) {
+		return false
+	}
+
+	if missing := e.missingScopes(u, collector); len(missing) > 0 {
+		e.Logger.Warn("Not collecting metrics, user token is missing scopes", "user", u.name, "collector", collector, "missingScopes", missing)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,86 @@
+package collectors
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A secret read from a file, like a mounted Kubernetes or Docker secret
+type secretFile struct {
+	path  string
+	value string
+}
+
+// ReadSecretFile returns the content of a secret file without surrounding
+// whitespace
+func ReadSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read secret file: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Reads the file again and returns the secret if it changed since the last
+// read. Only changes are returned so tokens refreshed by the exporter are not
+// replaced by the older ones still in the file.
+func (f *secretFile) changed() (string, bool, error) {
+	if f.path == "" {
+		return "", false, nil
+	}
+
+	value, err := ReadSecretFile(f.path)
+	if err != nil {
+		return "", false, err
+	}
+
+	if value == f.value {
+		return "", false, nil
+	}
+	f.value = value
+
+	return value, true, nil
+}
+
+// Picks up secrets rotated on their files, must be called holding tokenMu
+func (e *Exporter) readSecretFiles() {
+	secret, changed, err := e.clientSecret.changed()
+	if err != nil {
+		e.Logger.Error(err.Error())
+	}
+
+	if changed {
+		e.Logger.Info("Client secret changed, using the new secret")
+		e.Settings.ApiSettings.Options.ClientSecret = secret
+		authClient, err := newAuthClient(e.Settings)
+		if err != nil {
+			e.Logger.Error("Failed to create twitch client", "err", err)
+		} else {
+			e.authClient = authClient
+		}
+	}
+
+	for _, u := range e.sessions {
+		token, changed, err := u.accessTokenFile.changed()
+		if err != nil {
+			e.Logger.Error(err.Error(), "user", u.name)
+		}
+
+		if changed {
+			e.Logger.Info("Access token changed, using the new token", "user", u.name)
+			u.client.SetUserAccessToken(token)
+		}
+
+		token, changed, err = u.refreshTokenFile.changed()
+		if err != nil {
+			e.Logger.Error(err.Error(), "user", u.name)
+		}
+
+		if changed {
+			e.Logger.Info("Refresh token changed, using the new token", "user", u.name)
+			u.refreshToken = token
+		}
+	}
+}
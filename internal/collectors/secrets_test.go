@@ -0,0 +1,42 @@
+package collectors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	tokenPath := filepath.Join(dir, "access_token")
+	secretPath := filepath.Join(dir, "client_secret")
+	_ = os.WriteFile(tokenPath, []byte("token\n"), 0o600)
+	_ = os.WriteFile(secretPath, []byte("secret"), 0o600)
+
+	e, _ := newTestExporter(t, nil, &Settings{
+		UserToken: true,
+		Users:     []TwitchUser{{Name: "cool4pso", AccessToken: "token", AccessTokenFile: tokenPath}},
+	})
+	e.clientSecret = secretFile{path: secretPath, value: "secret"}
+	u := e.sessions[0]
+
+	// Tokens refreshed by the exporter are kept while the file does not change
+	u.client.SetUserAccessToken("refreshed")
+	e.readSecretFiles()
+	if token := u.client.GetUserAccessToken(); token != "refreshed" {
+		t.Errorf("expected refreshed token to be kept, got %v", token)
+	}
+
+	_ = os.WriteFile(tokenPath, []byte("rotated\n"), 0o600)
+	_ = os.WriteFile(secretPath, []byte("rotatedSecret"), 0o600)
+	authClient := e.authClient
+	e.readSecretFiles()
+
+	if token := u.client.GetUserAccessToken(); token != "rotated" {
+		t.Errorf("expected rotated token, got %v", token)
+	}
+
+	if e.authClient == authClient || e.Settings.ApiSettings.Options.ClientSecret != "rotatedSecret" {
+		t.Errorf("expected the auth client to use the rotated client secret")
+	}
+}
@@ -0,0 +1,333 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	helix "github.com/nicklaw5/helix/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector(collectorInfo{
+		name:           "stream",
+		scope:          channelScope,
+		defaultEnabled: true,
+		factory:        newStreamCollector,
+	})
+}
+
+// Collects if the channels are live, their viewer count and how it changes
+type streamCollector struct {
+	e            *Exporter
+	isLive       *channelDesc
+	viewerCount  *channelDesc
+	viewerChange *channelDesc
+	viewerStddev *channelDesc
+	anomalyScore *channelDesc
+
+	mu sync.Mutex
+	// Viewer counts of the live channels, reset when they go offline
+	windows map[string]*viewerWindow
+}
+
+func newStreamCollector(e *Exporter) collector {
+	return &streamCollector{
+		e: e,
+		isLive: newChannelDesc(
+			prometheus.BuildFQName(namespace, "", "is_live"),
+			"If twitch channel is broadcasting",
+		),
+		viewerCount: newChannelDesc(
+			prometheus.BuildFQName(namespace, "", "viewer_total"),
+			"Channel current viewer count",
+		),
+		viewerChange: newChannelDesc(
+			prometheus.BuildFQName(namespace, "", "viewer_change"),
+			"Channel viewer count change since the previous refresh",
+		),
+		viewerStddev: newChannelDesc(
+			prometheus.BuildFQName(namespace, "", "viewer_stddev"),
+			"Standard deviation of the channel viewer count within the viewers window",
+		),
+		anomalyScore: newChannelDesc(
+			prometheus.BuildFQName(namespace, "", "viewer_anomaly_score"),
+			"Z-score of the channel viewer count against the viewers window before it",
+		),
+		windows: make(map[string]*viewerWindow),
+	}
+}
+
+func (c *streamCollector) Describe(ch chan<- *prometheus.Desc) {
+	labelNames := c.e.currentLabelNames()
+	ch <- c.isLive.desc(labelNames)
+	ch <- c.viewerCount.desc(labelNames)
+	ch <- c.viewerChange.desc(labelNames)
+	ch <- c.viewerStddev.desc(labelNames)
+	ch <- c.anomalyScore.desc(labelNames)
+}
+
+func (c *streamCollector) Update(s *scrape, ch chan<- prometheus.Metric) error {
+	c.e.configMu.RLock()
+	settings := c.e.Settings.Viewers
+	c.e.configMu.RUnlock()
+
+	channels := s.channelsFor("stream")
+
+	var errs []error
+	var names []string
+	for _, twitchChannel := range channels {
+		names = append(names, twitchChannel.Name)
+	}
+
+	ids, err := c.e.channelIDs(names, !s.probe)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	var found []*TwitchChannel
+	for _, twitchChannel := range channels {
+		if _, ok := ids[strings.ToLower(twitchChannel.Name)]; ok {
+			found = append(found, twitchChannel)
+		} else if err == nil {
+			errs = append(errs, fmt.Errorf("Could not find channel %v", twitchChannel.Name))
+		}
+	}
+
+	states, err := c.e.streamStates(found, ids)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, twitchChannel := range found {
+		state, ok := states[twitchChannel.Name]
+		if !ok {
+			continue
+		}
+
+		s.channelMetric(ch, c.isLive, prometheus.GaugeValue, float64(state.isLive), twitchChannel)
+		s.channelMetric(ch, c.viewerCount, prometheus.GaugeValue, float64(state.viewerCount), twitchChannel)
+
+		window := c.viewerWindow(twitchChannel.Name, state, settings, !s.probe)
+		if window == nil {
+			continue
+		}
+
+		if change, ok := window.change(); ok {
+			s.channelMetric(ch, c.viewerChange, prometheus.GaugeValue, change, twitchChannel)
+		}
+
+		if stddev, ok := window.stddev(settings.minSamples()); ok {
+			s.channelMetric(ch, c.viewerStddev, prometheus.GaugeValue, stddev, twitchChannel)
+		}
+
+		if score, ok := window.anomalyScore(settings.minSamples()); ok {
+			s.channelMetric(ch, c.anomalyScore, prometheus.GaugeValue, score, twitchChannel)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *streamCollector) pruneChannels(s *scrape) {
+	keep := s.channelNamesFor("stream")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name := range c.windows {
+		if !keep[name] {
+			delete(c.windows, name)
+		}
+	}
+}
+
+// Adds the viewer count of the state to the channel window when the sample
+// interval passed since the last sample, and returns a copy of the window.
+// Returns nil when the channel is not live, the next stream starts a new
+// window. Without feed the window is only read.
+func (c *streamCollector) viewerWindow(name string, state channelState, settings *Viewers, feed bool) *viewerWindow {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !feed {
+		window, ok := c.windows[name]
+		if !ok || state.isLive == 0 {
+			return nil
+		}
+
+		return &viewerWindow{samples: slices.Clone(window.samples)}
+	}
+
+	if state.isLive == 0 {
+		delete(c.windows, name)
+		return nil
+	}
+
+	window, ok := c.windows[name]
+	if !ok {
+		window = &viewerWindow{}
+		c.windows[name] = window
+	}
+
+	if latest := window.latest(); latest.IsZero() || state.refreshedAt.Sub(latest) >= settings.interval() {
+		window.add(state.refreshedAt, state.viewerCount, settings.window())
+	}
+
+	return &viewerWindow{samples: slices.Clone(window.samples)}
+}
+
+// Returns the user ids of the channels by lowercase login. Ids don't change,
+// only the channels not resolved before are requested and the new ones are
+// kept when cache is set.
+func (e *Exporter) channelIDs(names []string, cache bool) (map[string]string, error) {
+	ids := make(map[string]string)
+	var missing []string
+
+	e.cacheMu.Lock()
+	for _, name := range names {
+		login := strings.ToLower(name)
+		if id, ok := e.idCache[login]; ok {
+			ids[login] = id
+		} else {
+			missing = append(missing, login)
+		}
+	}
+	e.cacheMu.Unlock()
+
+	for start := 0; start < len(missing); start += channelBatchSize {
+		users, err := e.channelUsers(missing[start:min(start+channelBatchSize, len(missing))])
+		if err != nil {
+			return ids, err
+		}
+
+		e.cacheMu.Lock()
+		for login, u := range users {
+			if cache {
+				e.idCache[login] = u.ID
+			}
+			ids[login] = u.ID
+		}
+		e.cacheMu.Unlock()
+	}
+
+	return ids, nil
+}
+
+// Returns the stream state of the channels by name, ids by lowercase login.
+// The api is only queried for the channels whose refresh interval passed
+// since the last query, up to 100 channels per request. Channels of a failed
+// request are left out.
+func (e *Exporter) streamStates(channels []*TwitchChannel, ids map[string]string) (map[string]channelState, error) {
+	states := make(map[string]channelState)
+	var due []*TwitchChannel
+
+	e.cacheMu.Lock()
+	for _, c := range channels {
+		state, ok := e.channelCache[c.Name]
+		if ok && c.RefreshInterval > 0 && time.Since(state.refreshedAt) < c.RefreshInterval {
+			e.Logger.Debug("using cached channel state", "channelName", c.Name)
+			states[c.Name] = state
+			continue
+		}
+		due = append(due, c)
+	}
+	e.cacheMu.Unlock()
+
+	var errs []error
+	for start := 0; start < len(due); start += channelBatchSize {
+		batch := due[start:min(start+channelBatchSize, len(due))]
+
+		var batchIDs []string
+		for _, c := range batch {
+			batchIDs = append(batchIDs, ids[strings.ToLower(c.Name)])
+		}
+
+		streams, err := e.streams(batchIDs)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		refreshedAt := time.Now()
+		e.cacheMu.Lock()
+		for _, c := range batch {
+			state := channelState{refreshedAt: refreshedAt}
+			if stream, ok := streams[ids[strings.ToLower(c.Name)]]; ok {
+				state.isLive = isLive(&stream)
+				state.viewerCount = stream.ViewerCount
+			}
+
+			states[c.Name] = state
+			if c.RefreshInterval > 0 {
+				e.channelCache[c.Name] = state
+			}
+		}
+		e.cacheMu.Unlock()
+	}
+
+	return states, errors.Join(errs...)
+}
+
+// Returns the streams of the channels by user id, channels that are not
+// streaming have none. Takes up to 100 user ids.
+func (e *Exporter) streams(ids []string) (map[string]helix.Stream, error) {
+	e.Logger.Debug("getting channel streams", "userIDs", ids)
+	resp, err := e.client.GetStreams(&helix.StreamsParams{
+		UserIDs: ids,
+		First:   len(ids),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get channel streams: %w", err)
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Failed to get channel streams, status code %v: %v", resp.StatusCode, resp.ErrorMessage)
+	}
+
+	streams := make(map[string]helix.Stream)
+	for _, stream := range resp.Data.Streams {
+		streams[stream.UserID] = stream
+	}
+
+	return streams, nil
+}
+
+// Returns 1 if broadcasting live, 0 if not. Reruns and streams with any
+// other type are not live.
+func isLive(stream *helix.Stream) int {
+	if stream.Type == "live" {
+		return 1
+	}
+
+	return 0
+}
+
+// Returns the stream of the channel by user id, or nil if the channel is not
+// streaming. The id matches exactly, unlike the login or display name.
+func (e *Exporter) stream(channelName, id string) (*helix.Stream, error) {
+	e.Logger.Debug("getting channel stream", "channelName", channelName, "userID", id)
+	resp, err := e.client.GetStreams(&helix.StreamsParams{
+		UserIDs: []string{id},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get channel %v stream: %w", channelName, err)
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Failed to get channel %v stream, status code %v: %v", channelName, resp.StatusCode, resp.ErrorMessage)
+	}
+
+	for _, stream := range resp.Data.Streams {
+		if stream.UserID == id {
+			e.Logger.Debug("Got channel stream", "channelName", channelName, "type", stream.Type, "count", stream.ViewerCount)
+			return &stream, nil
+		}
+	}
+
+	return nil, nil
+}
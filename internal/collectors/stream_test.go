@@ -0,0 +1,155 @@
+package collectors
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamCollector(t *testing.T) {
+	tests := []struct {
+		name            string
+		users           string
+		streams         string
+		expectedErr     bool
+		expectedMetrics map[string]float64
+	}{
+		{
+			name:    "Live",
+			users:   `{"data":[{"id":"1","login":"cool4pso","display_name":"cool4pso"}]}`,
+			streams: `{"data":[{"user_id":"1","user_login":"cool4pso","type":"live","viewer_count":42}]}`,
+			expectedMetrics: map[string]float64{
+				"twitch_is_live{cool4pso}":      1,
+				"twitch_viewer_total{cool4pso}": 42,
+			},
+		},
+		{
+			name:    "Localized display name",
+			users:   `{"data":[{"id":"1","login":"cool4pso","display_name":"クール"}]}`,
+			streams: `{"data":[{"user_id":"1","user_login":"cool4pso","user_name":"クール","type":"live","viewer_count":7}]}`,
+			expectedMetrics: map[string]float64{
+				"twitch_is_live{cool4pso}":      1,
+				"twitch_viewer_total{cool4pso}": 7,
+			},
+		},
+		{
+			name:    "Rerun",
+			users:   `{"data":[{"id":"1","login":"cool4pso"}]}`,
+			streams: `{"data":[{"user_id":"1","user_login":"cool4pso","type":"rerun","viewer_count":3}]}`,
+			expectedMetrics: map[string]float64{
+				"twitch_is_live{cool4pso}":      0,
+				"twitch_viewer_total{cool4pso}": 3,
+			},
+		},
+		{
+			name:    "Empty stream type",
+			users:   `{"data":[{"id":"1","login":"cool4pso"}]}`,
+			streams: `{"data":[{"user_id":"1","user_login":"cool4pso","type":"","viewer_count":0}]}`,
+			expectedMetrics: map[string]float64{
+				"twitch_is_live{cool4pso}": 0,
+			},
+		},
+		{
+			name:    "Offline",
+			users:   `{"data":[{"id":"1","login":"cool4pso"}]}`,
+			streams: `{"data":[]}`,
+			expectedMetrics: map[string]float64{
+				"twitch_is_live{cool4pso}":      0,
+				"twitch_viewer_total{cool4pso}": 0,
+			},
+		},
+		{
+			name:    "Stream of another channel",
+			users:   `{"data":[{"id":"1","login":"cool4pso"}]}`,
+			streams: `{"data":[{"user_id":"2","user_login":"cool4pso_","type":"live","viewer_count":9}]}`,
+			expectedMetrics: map[string]float64{
+				"twitch_is_live{cool4pso}": 0,
+			},
+		},
+		{
+			name:            "Unknown channel",
+			users:           `{"data":[]}`,
+			streams:         `{"data":[]}`,
+			expectedErr:     true,
+			expectedMetrics: map[string]float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, fake := newTestExporter(t, map[string][]fakeResponse{
+				usersPath:   {{200, tt.users}},
+				streamsPath: {{200, tt.streams}},
+			}, &Settings{})
+
+			s := &scrape{channels: []TwitchChannel{{Name: "cool4pso", RefreshInterval: time.Minute}}}
+			metrics, err := updateMetrics(t, e.collectors["stream"], s)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error: %v, got: %v", tt.expectedErr, err)
+			}
+
+			for name, value := range tt.expectedMetrics {
+				if got, ok := metrics[name]; !ok || got != value {
+					t.Errorf("expected %v %v, got %v", name, value, metrics)
+				}
+			}
+
+			if tt.expectedErr {
+				return
+			}
+
+			// Ids and cached stream states are reused
+			_, _ = updateMetrics(t, e.collectors["stream"], s)
+			if calls := fake.callCount(usersPath) + fake.callCount(streamsPath); calls != 2 {
+				t.Errorf("expected 2 api calls, got %v", calls)
+			}
+		})
+	}
+}
+
+func TestStreamCollectorBatches(t *testing.T) {
+	tests := []struct {
+		name          string
+		channels      int
+		expectedCalls int
+	}{
+		{name: "One channel", channels: 1, expectedCalls: 1},
+		{name: "Full batch", channels: 100, expectedCalls: 1},
+		{name: "Three batches", channels: 201, expectedCalls: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var users []string
+			var channels []TwitchChannel
+			for i := 1; i <= tt.channels; i++ {
+				users = append(users, fmt.Sprintf(`{"id":"%v","login":"channel%v"}`, i, i))
+				channels = append(channels, TwitchChannel{Name: fmt.Sprintf("channel%v", i)})
+			}
+
+			e, fake := newTestExporter(t, map[string][]fakeResponse{
+				usersPath:   {{200, `{"data":[` + strings.Join(users, ",") + `]}`}},
+				streamsPath: {{200, fmt.Sprintf(`{"data":[{"user_id":"%v","type":"live","viewer_count":42}]}`, tt.channels)}},
+			}, &Settings{})
+
+			metrics, err := updateMetrics(t, e.collectors["stream"], &scrape{channels: channels})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			last := fmt.Sprintf("channel%v", tt.channels)
+			if metrics["twitch_viewer_total{"+last+"}"] != 42 || metrics["twitch_is_live{"+last+"}"] != 1 {
+				t.Errorf("expected %v live with 42 viewers, got %v", last, metrics)
+			}
+
+			if live, ok := metrics["twitch_is_live{channel1}"]; tt.channels > 1 && (!ok || live != 0) {
+				t.Errorf("expected channel1 offline, got %v", metrics)
+			}
+
+			if calls := fake.callCount(streamsPath); calls != tt.expectedCalls {
+				t.Errorf("expected %v streams requests, got %v", tt.expectedCalls, calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package collectors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	helix "github.com/nicklaw5/helix/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector(collectorInfo{
+		name:           "subscriptions",
+		scope:          userScope,
+		defaultEnabled: true,
+		scopes:         []string{"channel:read:subscriptions"},
+		factory:        newSubscriptionsCollector,
+	})
+}
+
+// Subscribers of a user by user id, compared with the next snapshot to count
+// the new and lost subscriptions
+type subscriptionSnapshot struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// Subscription changes of a user since the exporter started
+type subscriptionState struct {
+	snapshot map[string]bool
+	new      int
+	lost     int
+	gifted   int
+}
+
+// Collects the subscriber count and subscription changes of the authenticated
+// users
+type subscriptionsCollector struct {
+	e              *Exporter
+	subCount       *prometheus.Desc
+	newSubs        *prometheus.Desc
+	lostSubs       *prometheus.Desc
+	giftedReceived *prometheus.Desc
+	gifted         *prometheus.Desc
+	gifters        *prometheus.Desc
+
+	mu     sync.Mutex
+	states map[string]*subscriptionState
+}
+
+func newSubscriptionsCollector(e *Exporter) collector {
+	return &subscriptionsCollector{
+		e: e,
+		subCount: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "subscribers_total"),
+			"Channel current total subscribers",
+			[]string{"name"}, nil,
+		),
+		newSubs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "subscriptions", "new_total"),
+			"Number of subscribers that were not in the previous subscriptions snapshot",
+			[]string{"name"}, nil,
+		),
+		lostSubs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "subscriptions", "lost_total"),
+			"Number of subscribers of the previous subscriptions snapshot that are gone",
+			[]string{"name"}, nil,
+		),
+		giftedReceived: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "subscriptions", "gifted_received_total"),
+			"Number of new subscribers whose subscription is a gift",
+			[]string{"name"}, nil,
+		),
+		gifted: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "subscriptions", "gifted"),
+			"Number of current subscriptions that are gifts",
+			[]string{"name"}, nil,
+		),
+		gifters: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "subscriptions", "gifters"),
+			"Number of users who gifted the current subscriptions",
+			[]string{"name"}, nil,
+		),
+		states: make(map[string]*subscriptionState),
+	}
+}
+
+func (c *subscriptionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.subCount
+	ch <- c.newSubs
+	ch <- c.lostSubs
+	ch <- c.giftedReceived
+	ch <- c.gifted
+	ch <- c.gifters
+}
+
+func (c *subscriptionsCollector) Update(s *scrape, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for _, u := range c.e.sessionsFor("subscriptions") {
+		subs, err := c.e.subscriptions(u)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		state, ok := c.states[u.name]
+		if !ok {
+			state = &subscriptionState{snapshot: c.e.loadSubscriptionSnapshot(u.name)}
+			c.states[u.name] = state
+		}
+
+		state.update(subs)
+		c.e.saveSubscriptionSnapshot(u.name, state.snapshot)
+
+		gifted := 0
+		gifters := make(map[string]bool)
+		for _, sub := range subs {
+			if sub.IsGift {
+				gifted++
+				gifters[sub.GifterID] = true
+			}
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.subCount, prometheus.GaugeValue, float64(len(subs)), u.name)
+		ch <- prometheus.MustNewConstMetric(c.newSubs, prometheus.CounterValue, float64(state.new), u.name)
+		ch <- prometheus.MustNewConstMetric(c.lostSubs, prometheus.CounterValue, float64(state.lost), u.name)
+		ch <- prometheus.MustNewConstMetric(c.giftedReceived, prometheus.CounterValue, float64(state.gifted), u.name)
+		ch <- prometheus.MustNewConstMetric(c.gifted, prometheus.GaugeValue, float64(gifted), u.name)
+		ch <- prometheus.MustNewConstMetric(c.gifters, prometheus.GaugeValue, float64(len(gifters)), u.name)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Counts the subscriptions added and removed since the previous snapshot and
+// replaces it. Without a previous snapshot the subscriptions are the baseline,
+// none of them is new.
+func (s *subscriptionState) update(subs []helix.Subscription) {
+	snapshot := make(map[string]bool, len(subs))
+	for _, sub := range subs {
+		snapshot[sub.UserID] = true
+		if s.snapshot == nil || s.snapshot[sub.UserID] {
+			continue
+		}
+
+		s.new++
+		if sub.IsGift {
+			s.gifted++
+		}
+	}
+
+	for userID := range s.snapshot {
+		if !snapshot[userID] {
+			s.lost++
+		}
+	}
+
+	s.snapshot = snapshot
+}
+
+// Returns every subscription of the user channel
+func (e *Exporter) subscriptions(u *userSession) ([]helix.Subscription, error) {
+	e.Logger.Debug("getting user subscriptions", "user", u.name)
+	userID, err := e.getUserID(u)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &helix.SubscriptionsParams{BroadcasterID: userID, First: 100}
+	var subs []helix.Subscription
+	for {
+		resp, err := u.client.GetSubscriptions(params)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get %v subscribers: %w", u.name, err)
+		}
+
+		if resp.StatusCode != 200 {
+			return nil, fmt.Errorf("Failed to get %v subscribers, status code %v: %v", u.name, resp.StatusCode, resp.ErrorMessage)
+		}
+
+		subs = append(subs, resp.Data.Subscriptions...)
+		if resp.Data.Pagination.Cursor == "" || len(resp.Data.Subscriptions) == 0 {
+			break
+		}
+		params.After = resp.Data.Pagination.Cursor
+	}
+
+	e.Logger.Debug("got subcount", "subCount", len(subs))
+	return subs, nil
+}
+
+func (e *Exporter) subscriptionSnapshotPath(user string) string {
+	if e.Settings.StateDir == "" {
+		return ""
+	}
+
+	return filepath.Join(e.Settings.StateDir, "subscriptions_"+user+".json")
+}
+
+// Returns the snapshot saved by a previous run, nil when there is none
+func (e *Exporter) loadSubscriptionSnapshot(user string) map[string]bool {
+	path := e.subscriptionSnapshotPath(user)
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		e.Logger.Error("Failed to read subscriptions snapshot", "user", user, "err", err)
+		return nil
+	}
+
+	var snapshot subscriptionSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		e.Logger.Error("Failed to parse subscriptions snapshot", "user", user, "path", path, "err", err)
+		return nil
+	}
+
+	subs := make(map[string]bool, len(snapshot.UserIDs))
+	for _, userID := range snapshot.UserIDs {
+		subs[userID] = true
+	}
+
+	return subs
+}
+
+// Saves the snapshot for the next run, a failure only affects the counts
+// after a restart and is logged
+func (e *Exporter) saveSubscriptionSnapshot(user string, subs map[string]bool) {
+	path := e.subscriptionSnapshotPath(user)
+	if path == "" {
+		return
+	}
+
+	snapshot := subscriptionSnapshot{UserIDs: make([]string, 0, len(subs))}
+	for userID := range subs {
+		snapshot.UserIDs = append(snapshot.UserIDs, userID)
+	}
+	slices.Sort(snapshot.UserIDs)
+
+	if err := writeStateFile(path, snapshot); err != nil {
+		e.Logger.Error("Failed to save subscriptions snapshot", "user", user, "err", err)
+	}
+}
+
+// Writes the value as json to a temporary file renamed over the path, a
+// crash never leaves a partial file behind
+func writeStateFile(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
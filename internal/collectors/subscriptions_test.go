@@ -0,0 +1,107 @@
+package collectors
+
+import (
+	"testing"
+
+	helix "github.com/nicklaw5/helix/v2"
+)
+
+const subscriptionsPath = "/helix/subscriptions"
+
+func TestSubscriptionsCollector(t *testing.T) {
+	stateDir := t.TempDir()
+	newExporter := func(snapshots ...string) *Exporter {
+		var responses []fakeResponse
+		for _, snapshot := range snapshots {
+			responses = append(responses, fakeResponse{200, snapshot})
+		}
+
+		e, _ := newTestExporter(t, map[string][]fakeResponse{
+			usersPath:         {{200, `{"data":[{"id":"1","login":"cool4pso"}]}`}},
+			subscriptionsPath: responses,
+		}, &Settings{
+			UserToken:  true,
+			Users:      []TwitchUser{{Name: "cool4pso", AccessToken: "token"}},
+			Collectors: map[string]bool{"subscriptions": true},
+			StateDir:   stateDir,
+		})
+
+		return e
+	}
+
+	tests := []struct {
+		name            string
+		restart         bool
+		snapshots       []string
+		expectedMetrics map[string]float64
+	}{
+		{
+			name: "First snapshot is the baseline",
+			snapshots: []string{
+				`{"data":[{"user_id":"2"},{"user_id":"3"}],"pagination":{"cursor":"next"}}`,
+				`{"data":[{"user_id":"4","is_gift":true,"gifter_id":"9"}]}`,
+			},
+			expectedMetrics: map[string]float64{
+				"twitch_subscribers_total{cool4pso}":                   3,
+				"twitch_subscriptions_new_total{cool4pso}":             0,
+				"twitch_subscriptions_lost_total{cool4pso}":            0,
+				"twitch_subscriptions_gifted_received_total{cool4pso}": 0,
+				"twitch_subscriptions_gifted{cool4pso}":                1,
+				"twitch_subscriptions_gifters{cool4pso}":               1,
+			},
+		},
+		{
+			name:    "Changes while down are counted after a restart",
+			restart: true,
+			snapshots: []string{
+				`{"data":[{"user_id":"2"},{"user_id":"5","is_gift":true,"gifter_id":"9"},{"user_id":"6","is_gift":true,"gifter_id":"8"},{"user_id":"7"}]}`,
+			},
+			expectedMetrics: map[string]float64{
+				"twitch_subscribers_total{cool4pso}":                   4,
+				"twitch_subscriptions_new_total{cool4pso}":             3,
+				"twitch_subscriptions_lost_total{cool4pso}":            2,
+				"twitch_subscriptions_gifted_received_total{cool4pso}": 2,
+				"twitch_subscriptions_gifted{cool4pso}":                2,
+				"twitch_subscriptions_gifters{cool4pso}":               2,
+			},
+		},
+	}
+
+	var e *Exporter
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if e == nil || tt.restart {
+				e = newExporter(tt.snapshots...)
+			}
+
+			metrics, err := updateMetrics(t, e.collectors["subscriptions"], &scrape{})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			for name, value := range tt.expectedMetrics {
+				if got, ok := metrics[name]; !ok || got != value {
+					t.Errorf("expected %v %v, got %v", name, value, metrics)
+				}
+			}
+		})
+	}
+}
+
+func TestSubscriptionStateUpdate(t *testing.T) {
+	state := &subscriptionState{}
+	snapshots := [][]string{{"1", "2"}, {"1", "2", "3"}, {"3", "4"}, {"3", "4"}}
+	expected := []subscriptionState{{}, {new: 1}, {new: 2, lost: 2}, {new: 2, lost: 2}}
+
+	for i, snapshot := range snapshots {
+		var subs []helix.Subscription
+		for _, userID := range snapshot {
+			subs = append(subs, helix.Subscription{UserID: userID})
+		}
+
+		state.update(subs)
+		if state.new != expected[i].new || state.lost != expected[i].lost {
+			t.Errorf("snapshot %v: expected %v new and %v lost, got %v and %v", i, expected[i].new, expected[i].lost, state.new, state.lost)
+		}
+	}
+}
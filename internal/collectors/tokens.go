@@ -0,0 +1,516 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	helix "github.com/nicklaw5/helix/v2"
+)
+
+const (
+	tokenCheckInterval = 5 * time.Minute
+	// User tokens expiring within the margin are refreshed, it is larger than
+	// the check interval so tokens never expire between two checks
+	userTokenRefreshMargin = 30 * time.Minute
+	appTokenType           = "app"
+	userTokenType          = "user"
+)
+
+var (
+	errAuthorizationPending = errors.New("Authentication flow not completed, please use the authorization url to obtain an access token, or provide an access token")
+)
+
+// TokenError is returned when requesting, refreshing or validating a token
+// fails. StatusCode is 0 when twitch could not be reached.
+type TokenError struct {
+	Op         string
+	StatusCode int
+	Message    string
+	Err        error
+}
+
+func (e *TokenError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("Failed to %v: %v", e.Op, e.Err)
+	}
+
+	return fmt.Sprintf("Failed to %v, status code %v: %v", e.Op, e.StatusCode, e.Message)
+}
+
+func (e *TokenError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports if the request may succeed if tried again, network errors,
+// rate limiting and twitch server errors are retryable, everything else means
+// the request itself is wrong and retrying won't help
+func (e *TokenError) Retryable() bool {
+	return e.StatusCode == 0 ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= http.StatusInternalServerError
+}
+
+// LoginMismatchError is returned when a user authorizes the exporter while
+// logged in to twitch with another account
+type LoginMismatchError struct {
+	Login string
+	User  string
+}
+
+func (e *LoginMismatchError) Error() string {
+	return fmt.Sprintf("Token belongs to %v, not to %v, please log in to twitch as %v and authorize again", e.Login, e.User, e.User)
+}
+
+// Returns a TokenError if the request failed, nil otherwise
+func checkTokenResponse(op string, resp helix.ResponseCommon, err error) error {
+	if err != nil {
+		return &TokenError{Op: op, Err: err}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &TokenError{Op: op, StatusCode: resp.StatusCode, Message: resp.ErrorMessage}
+	}
+
+	return nil
+}
+
+// Health of a token, exported by the token metrics
+type tokenHealth struct {
+	valid     bool
+	expiresAt time.Time
+	login     string
+	refreshes map[string]int
+}
+
+func (h *tokenHealth) isValid() bool {
+	return h.valid && time.Now().Before(h.expiresAt)
+}
+
+// Authorization holds the details of a completed authorization code exchange
+type Authorization struct {
+	User      string
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+// Authorize exchanges the authorization code received on the oauth redirect
+// for a user access token, so authentication problems are reported straight
+// away to the user instead of showing up on the next scrape. Authorization
+// codes can only be used once so the exchange is never retried.
+func (e *Exporter) Authorize(user, code string) (*Authorization, error) {
+	u, err := e.userSession(user)
+	if err != nil {
+		return nil, err
+	}
+
+	e.tokenMu.Lock()
+	defer e.tokenMu.Unlock()
+
+	e.Logger.Debug("exchanging authorization code for user token", "user", u.name)
+	resp, err := e.authClient.RequestUserAccessToken(code)
+	if resp == nil {
+		resp = &helix.UserAccessTokenResponse{}
+	}
+
+	if err := checkTokenResponse("exchange authorization code", resp.ResponseCommon, err); err != nil {
+		return nil, err
+	}
+
+	// The token belongs to whoever is logged in to twitch on the browser,
+	// make sure it is the user being authorized
+	_, validation, err := e.authClient.ValidateToken(resp.Data.AccessToken)
+	if validation == nil {
+		validation = &helix.ValidateTokenResponse{}
+	}
+
+	if err := checkTokenResponse("validate user token", validation.ResponseCommon, err); err != nil {
+		return nil, err
+	}
+
+	if validation.Data.Login != u.name {
+		return nil, &LoginMismatchError{Login: validation.Data.Login, User: u.name}
+	}
+
+	expiresAt := time.Now().Add(time.Duration(resp.Data.ExpiresIn) * time.Second)
+	u.client.SetUserAccessToken(resp.Data.AccessToken)
+	u.refreshToken = resp.Data.RefreshToken
+	e.setTokenHealth(&u.token, true, expiresAt)
+	e.setTokenLogin(&u.token, validation.Data.Login)
+	e.setGrantedScopes(u, resp.Data.Scopes)
+	e.Logger.Debug("new user token set", "user", u.name, "scopes", resp.Data.Scopes, "expiresAt", expiresAt)
+	e.logMissingScopes(u)
+
+	return &Authorization{
+		User:      u.name,
+		Scopes:    resp.Data.Scopes,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Keeps the tokens valid in the background so scrapes never have to
+// request or refresh tokens themselves
+func (e *Exporter) manageTokens() {
+	ticker := time.NewTicker(tokenCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		e.handleTokens()
+	}
+}
+
+func (e *Exporter) setTokenHealth(h *tokenHealth, valid bool, expiresAt time.Time) {
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+
+	h.valid = valid
+	h.expiresAt = expiresAt
+}
+
+func (e *Exporter) setTokenValid(h *tokenHealth, valid bool) {
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+
+	h.valid = valid
+}
+
+func (e *Exporter) setTokenLogin(h *tokenHealth, login string) {
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+
+	h.login = login
+}
+
+// Counts token requests and refreshes by result, success or failure
+func (e *Exporter) countTokenRefresh(h *tokenHealth, err error) {
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+
+	if h.refreshes == nil {
+		h.refreshes = make(map[string]int)
+	}
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	h.refreshes[result]++
+}
+
+func (e *Exporter) handleTokens() {
+	e.tokenMu.Lock()
+	defer e.tokenMu.Unlock()
+
+	e.readSecretFiles()
+
+	if err := e.handleAppTokens(); err != nil {
+		e.Logger.Error(err.Error())
+	}
+
+	for _, u := range e.sessions {
+		if err := e.handleUserTokens(u); err != nil {
+			e.Logger.Error(err.Error(), "user", u.name)
+		}
+	}
+}
+
+func (e *Exporter) handleAppTokens() error {
+	if !e.isAppTokenExpired() {
+		return nil
+	}
+
+	e.Logger.Info("getting new application token")
+	return e.setNewAppToken()
+}
+
+func (e *Exporter) handleUserTokens(u *userSession) error {
+	if u.client.GetUserAccessToken() == "" {
+		e.setTokenValid(&u.token, false)
+		return errAuthorizationPending
+	}
+
+	valid, err := e.validateUserToken(u)
+	if err != nil {
+		// Twitch could not tell if the token is valid, keep using it
+		return err
+	}
+
+	if valid && (u.refreshToken == "" || !e.userTokenExpiring(u)) {
+		e.Logger.Debug("token is valid", "user", u.name)
+		return nil
+	}
+
+	if valid {
+		e.Logger.Info("User token about to expire, refreshing", "user", u.name)
+		return e.refreshUserToken(u)
+	}
+
+	e.setTokenValid(&u.token, false)
+	if u.refreshToken == "" {
+		return fmt.Errorf("Access token available, but no refresh token provided, Please re-authenticate again, or provide a refresh token")
+	}
+
+	e.Logger.Info("User token no longer valid, refreshing", "user", u.name)
+	return e.refreshUserToken(u)
+}
+
+// Returns false when twitch reports the user token as invalid, and an error
+// when the token validity could not be checked
+func (e *Exporter) validateUserToken(u *userSession) (bool, error) {
+	e.Logger.Debug("validating user token", "user", u.name)
+	isValid, resp, err := e.authClient.ValidateToken(u.client.GetUserAccessToken())
+	if resp == nil {
+		resp = &helix.ValidateTokenResponse{}
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return false, nil
+	}
+
+	if err := checkTokenResponse("validate user token", resp.ResponseCommon, err); err != nil {
+		return false, err
+	}
+
+	e.setTokenHealth(&u.token, isValid, time.Now().Add(time.Duration(resp.Data.ExpiresIn)*time.Second))
+	e.setTokenLogin(&u.token, resp.Data.Login)
+	e.setGrantedScopes(u, resp.Data.Scopes)
+	return isValid, nil
+}
+
+// Reports if the user token expires before the check after the next one
+func (e *Exporter) userTokenExpiring(u *userSession) bool {
+	e.stateMu.RLock()
+	defer e.stateMu.RUnlock()
+
+	return time.Until(u.token.expiresAt) < userTokenRefreshMargin
+}
+
+func (e *Exporter) isAppTokenExpired() bool {
+	e.Logger.Debug("checking if application token is expired")
+	apiSettings := e.Settings.ApiSettings
+	secondsSinceIssued := time.Since(apiSettings.appTokenIssuedAt).Seconds()
+	thirtyMinutesInSeconds := 1800
+
+	if apiSettings.appTokenExpireIn <= 0 {
+		return true
+	}
+
+	return int(secondsSinceIssued) >= (apiSettings.appTokenExpireIn - thirtyMinutesInSeconds)
+}
+
+func (e *Exporter) requestAppToken() (*helix.AccessCredentials, error) {
+	resp, err := e.authClient.RequestAppAccessToken([]string{"user:read:email"})
+	if resp == nil {
+		resp = &helix.AppAccessTokenResponse{}
+	}
+
+	if err := checkTokenResponse("request app access token", resp.ResponseCommon, err); err != nil {
+		e.Logger.Warn(err.Error())
+		return nil, err
+	}
+
+	return &resp.Data, nil
+}
+
+// Requests a new application token, the previous token is kept if twitch
+// can't provide a new one
+func (e *Exporter) setNewAppToken() error {
+	e.Logger.Debug("setting new application token")
+	var creds *helix.AccessCredentials
+	err := e.retry.do(func() (err error) {
+		creds, err = e.requestAppToken()
+		return err
+	})
+	e.countTokenRefresh(&e.appToken, err)
+	if err != nil {
+		return err
+	}
+
+	issuedAt := time.Now()
+	e.client.SetAppAccessToken(creds.AccessToken)
+	e.Settings.ApiSettings.appTokenExpireIn = creds.ExpiresIn
+	e.Settings.ApiSettings.appTokenIssuedAt = issuedAt
+	e.setTokenHealth(&e.appToken, true, issuedAt.Add(time.Duration(creds.ExpiresIn)*time.Second))
+	e.Logger.Debug("new application token set")
+	return nil
+}
+
+func (e *Exporter) requestUserTokenRefresh(u *userSession) (*helix.AccessCredentials, error) {
+	resp, err := e.authClient.RefreshUserAccessToken(u.refreshToken)
+	if resp == nil {
+		resp = &helix.RefreshTokenResponse{}
+	}
+
+	if err := checkTokenResponse("refresh user access token", resp.ResponseCommon, err); err != nil {
+		e.Logger.Warn(err.Error(), "user", u.name)
+		return nil, err
+	}
+
+	return &resp.Data, nil
+}
+
+// Refreshes the user token, the previous tokens are kept if the refresh fails
+func (e *Exporter) refreshUserToken(u *userSession) error {
+	e.Logger.Debug("refreshing user token", "user", u.name)
+	var creds *helix.AccessCredentials
+	err := e.retry.do(func() (err error) {
+		creds, err = e.requestUserTokenRefresh(u)
+		return err
+	})
+	e.countTokenRefresh(&u.token, err)
+	if err != nil {
+		return err
+	}
+
+	u.client.SetUserAccessToken(creds.AccessToken)
+	u.refreshToken = creds.RefreshToken
+	e.setTokenHealth(&u.token, true, time.Now().Add(time.Duration(creds.ExpiresIn)*time.Second))
+	e.setGrantedScopes(u, creds.Scopes)
+	e.Logger.Debug("user token refreshed", "user", u.name)
+	return nil
+}
+
+// TokenInfo describes a token, it never includes the token itself
+type TokenInfo struct {
+	Type      string    `json:"type"`
+	User      string    `json:"user,omitempty"`
+	Valid     bool      `json:"valid"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Scopes    []string  `json:"scopes"`
+	Login     string    `json:"login,omitempty"`
+}
+
+// TokenInfo returns the details of the user token, validating it against
+// twitch, or of the application token when user is empty
+func (e *Exporter) TokenInfo(user string) (*TokenInfo, error) {
+	if user == "" {
+		e.stateMu.RLock()
+		defer e.stateMu.RUnlock()
+
+		return &TokenInfo{
+			Type:      appTokenType,
+			Valid:     e.appToken.isValid(),
+			ExpiresAt: e.appToken.expiresAt,
+			Scopes:    []string{},
+		}, nil
+	}
+
+	u, err := e.userSession(user)
+	if err != nil {
+		return nil, err
+	}
+
+	e.tokenMu.Lock()
+	defer e.tokenMu.Unlock()
+
+	if u.client.GetUserAccessToken() != "" {
+		valid, err := e.validateUserToken(u)
+		if err != nil {
+			return nil, err
+		}
+		e.setTokenValid(&u.token, valid)
+	}
+
+	e.stateMu.RLock()
+	defer e.stateMu.RUnlock()
+
+	info := &TokenInfo{
+		Type:      userTokenType,
+		User:      u.name,
+		Valid:     u.token.isValid(),
+		ExpiresAt: u.token.expiresAt,
+		Scopes:    []string{},
+		Login:     u.token.login,
+	}
+
+	for scope := range u.grantedScopes {
+		info.Scopes = append(info.Scopes, scope)
+	}
+	sort.Strings(info.Scopes)
+
+	return info, nil
+}
+
+// Tokens returns the details of the application token and every user token
+func (e *Exporter) Tokens() ([]*TokenInfo, error) {
+	info, err := e.TokenInfo("")
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := []*TokenInfo{info}
+	for _, u := range e.sessions {
+		info, err := e.TokenInfo(u.name)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, info)
+	}
+
+	return tokens, nil
+}
+
+// ForceTokenRefresh refreshes the user token, or requests a new application
+// token when user is empty, regardless of the current token expiry
+func (e *Exporter) ForceTokenRefresh(user string) error {
+	if user == "" {
+		e.tokenMu.Lock()
+		defer e.tokenMu.Unlock()
+
+		return e.setNewAppToken()
+	}
+
+	u, err := e.userSession(user)
+	if err != nil {
+		return err
+	}
+
+	e.tokenMu.Lock()
+	defer e.tokenMu.Unlock()
+
+	if u.refreshToken == "" {
+		return fmt.Errorf("No refresh token available, Please re-authenticate again, or provide a refresh token")
+	}
+
+	return e.refreshUserToken(u)
+}
+
+// RevokeToken revokes the user token on twitch and clears the stored tokens,
+// the user has to authorize the exporter again afterwards
+func (e *Exporter) RevokeToken(user string) error {
+	u, err := e.userSession(user)
+	if err != nil {
+		return err
+	}
+
+	e.tokenMu.Lock()
+	defer e.tokenMu.Unlock()
+
+	accessToken := u.client.GetUserAccessToken()
+	if accessToken == "" {
+		return errAuthorizationPending
+	}
+
+	e.Logger.Debug("revoking user token", "user", u.name)
+	resp, err := e.authClient.RevokeUserAccessToken(accessToken)
+	if resp == nil {
+		resp = &helix.RevokeAccessTokenResponse{}
+	}
+
+	if err := checkTokenResponse("revoke user access token", resp.ResponseCommon, err); err != nil {
+		return err
+	}
+
+	u.client.SetUserAccessToken("")
+	u.refreshToken = ""
+	e.setTokenHealth(&u.token, false, time.Time{})
+	e.setTokenLogin(&u.token, "")
+	e.stateMu.Lock()
+	u.grantedScopes = nil
+	e.stateMu.Unlock()
+	e.Logger.Info("User token revoked", "user", u.name)
+
+	return nil
+}
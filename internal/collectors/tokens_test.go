@@ -0,0 +1,525 @@
+package collectors
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	tokenPath     = "/oauth2/token"
+	validatePath  = "/oauth2/validate"
+	usersPath     = "/helix/users"
+	followersPath = "/helix/channels/followers"
+	networkError  = -1
+)
+
+type fakeResponse struct {
+	status int
+	body   string
+}
+
+// Fake twitch server, answers each path with the next response of its
+// sequence, repeating the last one once the sequence is over
+type fakeTwitch struct {
+	mu        sync.Mutex
+	responses map[string][]fakeResponse
+	calls     map[string]int
+}
+
+func (f *fakeTwitch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	responses := f.responses[r.URL.Path]
+	call := f.calls[r.URL.Path]
+	f.calls[r.URL.Path]++
+	f.mu.Unlock()
+
+	if len(responses) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	resp := responses[min(call, len(responses)-1)]
+	if resp.status == networkError {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.status)
+	_, _ = w.Write([]byte(resp.body))
+}
+
+func (f *fakeTwitch) callCount(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[path]
+}
+
+// Sends every request to the fake twitch server regardless of the host
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func newTestExporter(t *testing.T, responses map[string][]fakeResponse, s *Settings) (*Exporter, *fakeTwitch) {
+	t.Helper()
+
+	fake := &fakeTwitch{responses: responses, calls: make(map[string]int)}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	target, _ := url.Parse(srv.URL)
+	s.ApiSettings.Options.ClientID = "clientID"
+	s.ApiSettings.Options.ClientSecret = "clientSecret"
+	s.ApiSettings.Options.HTTPClient = &http.Client{Transport: rewriteTransport{target}}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client, err := newHelixClient(s)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	authClient, err := newAuthClient(s)
+	if err != nil {
+		t.Fatalf("failed to create auth client: %v", err)
+	}
+
+	sessions, err := newUserSessions(s, authClient, logger)
+	if err != nil {
+		t.Fatalf("failed to create user sessions: %v", err)
+	}
+
+	e := &Exporter{
+		client:       client,
+		authClient:   authClient,
+		sessions:     sessions,
+		retry:        retryPolicy{attempts: 3, initialDelay: time.Millisecond, maxDelay: 2 * time.Millisecond},
+		metrics:      newMetrics(),
+		labelNames:   channelLabelNames(s.Channels),
+		channelCache: make(map[string]channelState),
+		idCache:      make(map[string]string),
+		Settings:     s,
+		Logger:       logger,
+	}
+	e.collectors = newCollectors(e)
+
+	return e, fake
+}
+
+func checkTokenError(t *testing.T, err error, expectedStatus int) {
+	t.Helper()
+
+	if expectedStatus == 0 && err == nil {
+		return
+	}
+
+	var tokenErr *TokenError
+	if !errors.As(err, &tokenErr) {
+		t.Fatalf("expected TokenError, got: %v", err)
+	}
+
+	if expectedStatus != networkError && tokenErr.StatusCode != expectedStatus {
+		t.Errorf("expected status code %v, got: %v", expectedStatus, tokenErr.StatusCode)
+	}
+
+	if expectedStatus == networkError && tokenErr.StatusCode != 0 {
+		t.Errorf("expected network error, got status code: %v", tokenErr.StatusCode)
+	}
+}
+
+func TestSetNewAppToken(t *testing.T) {
+	tests := []struct {
+		name           string
+		responses      []fakeResponse
+		expectedToken  string
+		expectedStatus int
+		expectedCalls  int
+		expectedResult string
+	}{
+		{
+			name:           "New token",
+			responses:      []fakeResponse{{200, `{"access_token":"new","expires_in":3600}`}},
+			expectedToken:  "new",
+			expectedCalls:  1,
+			expectedResult: "success",
+		},
+		{
+			name:           "Retries server errors",
+			responses:      []fakeResponse{{500, ""}, {200, `{"access_token":"new","expires_in":3600}`}},
+			expectedToken:  "new",
+			expectedCalls:  2,
+			expectedResult: "success",
+		},
+		{
+			name:           "Keeps previous token after retries",
+			responses:      []fakeResponse{{503, ""}},
+			expectedToken:  "old",
+			expectedStatus: 503,
+			expectedCalls:  3,
+			expectedResult: "failure",
+		},
+		{
+			name:           "Does not retry bad requests",
+			responses:      []fakeResponse{{400, `{"status":400,"message":"invalid client secret"}`}},
+			expectedToken:  "old",
+			expectedStatus: 400,
+			expectedCalls:  1,
+			expectedResult: "failure",
+		},
+		{
+			name:           "Keeps previous token on network errors",
+			responses:      []fakeResponse{{networkError, ""}},
+			expectedToken:  "old",
+			expectedStatus: networkError,
+			expectedCalls:  3,
+			expectedResult: "failure",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, fake := newTestExporter(t, map[string][]fakeResponse{tokenPath: tt.responses}, &Settings{})
+			e.client.SetAppAccessToken("old")
+
+			err := e.setNewAppToken()
+			checkTokenError(t, err, tt.expectedStatus)
+
+			if token := e.client.GetAppAccessToken(); token != tt.expectedToken {
+				t.Errorf("expected token: %v, got: %v", tt.expectedToken, token)
+			}
+
+			if calls := fake.callCount(tokenPath); calls != tt.expectedCalls {
+				t.Errorf("expected %v token requests, got: %v", tt.expectedCalls, calls)
+			}
+
+			if e.appToken.refreshes[tt.expectedResult] != 1 {
+				t.Errorf("expected one %v refresh, got: %v", tt.expectedResult, e.appToken.refreshes)
+			}
+		})
+	}
+}
+
+func TestRefreshUserToken(t *testing.T) {
+	tests := []struct {
+		name                 string
+		responses            []fakeResponse
+		expectedToken        string
+		expectedRefreshToken string
+		expectedStatus       int
+		expectedCalls        int
+	}{
+		{
+			name:                 "Refreshed token",
+			responses:            []fakeResponse{{200, `{"access_token":"new","refresh_token":"newRefresh","expires_in":3600,"scope":["channel:read:subscriptions"]}`}},
+			expectedToken:        "new",
+			expectedRefreshToken: "newRefresh",
+			expectedCalls:        1,
+		},
+		{
+			name:                 "Retries rate limited requests",
+			responses:            []fakeResponse{{429, `{"status":429,"message":"too many requests"}`}, {200, `{"access_token":"new","refresh_token":"newRefresh","expires_in":3600}`}},
+			expectedToken:        "new",
+			expectedRefreshToken: "newRefresh",
+			expectedCalls:        2,
+		},
+		{
+			name:                 "Invalid refresh token",
+			responses:            []fakeResponse{{400, `{"status":400,"message":"Invalid refresh token"}`}},
+			expectedToken:        "old",
+			expectedRefreshToken: "oldRefresh",
+			expectedStatus:       400,
+			expectedCalls:        1,
+		},
+		{
+			name:                 "Network error",
+			responses:            []fakeResponse{{networkError, ""}},
+			expectedToken:        "old",
+			expectedRefreshToken: "oldRefresh",
+			expectedStatus:       networkError,
+			expectedCalls:        3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Settings{UserToken: true, Users: []TwitchUser{{Name: "cool4pso", AccessToken: "old", RefreshToken: "oldRefresh"}}}
+			e, fake := newTestExporter(t, map[string][]fakeResponse{tokenPath: tt.responses}, s)
+			u := e.sessions[0]
+
+			err := e.refreshUserToken(u)
+			checkTokenError(t, err, tt.expectedStatus)
+
+			if token := u.client.GetUserAccessToken(); token != tt.expectedToken {
+				t.Errorf("expected token: %v, got: %v", tt.expectedToken, token)
+			}
+
+			if u.refreshToken != tt.expectedRefreshToken {
+				t.Errorf("expected refresh token: %v, got: %v", tt.expectedRefreshToken, u.refreshToken)
+			}
+
+			if calls := fake.callCount(tokenPath); calls != tt.expectedCalls {
+				t.Errorf("expected %v token requests, got: %v", tt.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestHandleUserTokens(t *testing.T) {
+	tests := []struct {
+		name               string
+		accessToken        string
+		refreshToken       string
+		validate           []fakeResponse
+		token              []fakeResponse
+		expectedErr        error
+		expectedAnyErr     bool
+		expectedStatus     int
+		expectedToken      string
+		expectedTokenCalls int
+	}{
+		{
+			name:        "Authorization pending",
+			expectedErr: errAuthorizationPending,
+		},
+		{
+			name:          "Valid token",
+			accessToken:   "old",
+			validate:      []fakeResponse{{200, `{"expires_in":3600,"scopes":["channel:read:subscriptions"]}`}},
+			expectedToken: "old",
+		},
+		{
+			name:               "Token expiring before the next check is refreshed",
+			accessToken:        "old",
+			refreshToken:       "oldRefresh",
+			validate:           []fakeResponse{{200, `{"expires_in":240,"scopes":["channel:read:subscriptions"]}`}},
+			token:              []fakeResponse{{200, `{"access_token":"new","refresh_token":"newRefresh","expires_in":14400}`}},
+			expectedToken:      "new",
+			expectedTokenCalls: 1,
+		},
+		{
+			name:          "Expiring token without refresh token is kept",
+			accessToken:   "old",
+			validate:      []fakeResponse{{200, `{"expires_in":240,"scopes":["channel:read:subscriptions"]}`}},
+			expectedToken: "old",
+		},
+		{
+			name:           "Invalid token without refresh token",
+			accessToken:    "old",
+			validate:       []fakeResponse{{401, `{"status":401,"message":"invalid access token"}`}},
+			expectedAnyErr: true,
+			expectedToken:  "old",
+		},
+		{
+			name:               "Invalid token is refreshed",
+			accessToken:        "old",
+			refreshToken:       "oldRefresh",
+			validate:           []fakeResponse{{401, `{"status":401,"message":"invalid access token"}`}},
+			token:              []fakeResponse{{200, `{"access_token":"new","refresh_token":"newRefresh","expires_in":3600}`}},
+			expectedToken:      "new",
+			expectedTokenCalls: 1,
+		},
+		{
+			name:           "Validation unavailable keeps token",
+			accessToken:    "old",
+			refreshToken:   "oldRefresh",
+			validate:       []fakeResponse{{500, ""}},
+			expectedStatus: 500,
+			expectedToken:  "old",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Settings{UserToken: true, Users: []TwitchUser{{Name: "cool4pso", AccessToken: tt.accessToken, RefreshToken: tt.refreshToken}}}
+			e, fake := newTestExporter(t, map[string][]fakeResponse{validatePath: tt.validate, tokenPath: tt.token}, s)
+			u := e.sessions[0]
+
+			err := e.handleUserTokens(u)
+			switch {
+			case tt.expectedErr != nil:
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("expected error: %v, got: %v", tt.expectedErr, err)
+				}
+			case tt.expectedAnyErr:
+				if err == nil {
+					t.Errorf("expected error, got: nil")
+				}
+			default:
+				checkTokenError(t, err, tt.expectedStatus)
+			}
+
+			if token := u.client.GetUserAccessToken(); token != tt.expectedToken {
+				t.Errorf("expected token: %v, got: %v", tt.expectedToken, token)
+			}
+
+			if calls := fake.callCount(tokenPath); calls != tt.expectedTokenCalls {
+				t.Errorf("expected %v token requests, got: %v", tt.expectedTokenCalls, calls)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	newToken := fakeResponse{200, `{"access_token":"new","refresh_token":"newRefresh","expires_in":3600,"scope":["channel:read:subscriptions"]}`}
+	tests := []struct {
+		name           string
+		user           string
+		token          []fakeResponse
+		validate       []fakeResponse
+		expectedScopes []string
+		expectedAnyErr bool
+		expectedStatus int
+		expectedToken  string
+		expectedCalls  int
+	}{
+		{
+			name:           "Authorized",
+			user:           "cool4pso",
+			token:          []fakeResponse{newToken},
+			validate:       []fakeResponse{{200, `{"login":"cool4pso","expires_in":3600}`}},
+			expectedScopes: []string{"channel:read:subscriptions"},
+			expectedToken:  "new",
+			expectedCalls:  1,
+		},
+		{
+			name:           "Token from another user",
+			user:           "cool4pso",
+			token:          []fakeResponse{newToken},
+			validate:       []fakeResponse{{200, `{"login":"someoneelse","expires_in":3600}`}},
+			expectedAnyErr: true,
+			expectedCalls:  1,
+		},
+		{
+			name:           "Invalid authorization code is not retried",
+			user:           "cool4pso",
+			token:          []fakeResponse{{400, `{"status":400,"message":"Invalid authorization code"}`}},
+			expectedStatus: 400,
+			expectedCalls:  1,
+		},
+		{
+			name:           "Server error is not retried",
+			user:           "cool4pso",
+			token:          []fakeResponse{{500, ""}},
+			expectedStatus: 500,
+			expectedCalls:  1,
+		},
+		{
+			name:           "Unknown user",
+			user:           "unknown",
+			token:          []fakeResponse{newToken},
+			expectedAnyErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Settings{UserToken: true, Users: []TwitchUser{{Name: "cool4pso"}}}
+			e, fake := newTestExporter(t, map[string][]fakeResponse{tokenPath: tt.token, validatePath: tt.validate}, s)
+			u := e.sessions[0]
+
+			authorization, err := e.Authorize(tt.user, "code")
+			if tt.expectedAnyErr {
+				if err == nil {
+					t.Errorf("expected error, got: nil")
+				}
+			} else {
+				checkTokenError(t, err, tt.expectedStatus)
+			}
+
+			if token := u.client.GetUserAccessToken(); token != tt.expectedToken {
+				t.Errorf("expected token: %v, got: %v", tt.expectedToken, token)
+			}
+
+			if calls := fake.callCount(tokenPath); calls != tt.expectedCalls {
+				t.Errorf("expected %v token requests, got: %v", tt.expectedCalls, calls)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if len(authorization.Scopes) != len(tt.expectedScopes) || authorization.Scopes[0] != tt.expectedScopes[0] {
+				t.Errorf("expected scopes: %v, got: %v", tt.expectedScopes, authorization.Scopes)
+			}
+
+			if authorization.ExpiresAt.Before(time.Now()) {
+				t.Errorf("expected expiry in the future, got: %v", authorization.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestFollowerCount(t *testing.T) {
+	users := []fakeResponse{{200, `{"data":[{"id":"1","login":"cool4pso"}]}`}}
+	tests := []struct {
+		name          string
+		responses     map[string][]fakeResponse
+		expectedCount int
+		expectedErr   bool
+	}{
+		{
+			name:          "Follower count",
+			responses:     map[string][]fakeResponse{usersPath: users, followersPath: {{200, `{"total":42,"data":[]}`}}},
+			expectedCount: 42,
+		},
+		{
+			name:          "Server error",
+			responses:     map[string][]fakeResponse{usersPath: users, followersPath: {{500, ""}}},
+			expectedCount: 0,
+			expectedErr:   true,
+		},
+		{
+			name:          "Network error",
+			responses:     map[string][]fakeResponse{usersPath: users, followersPath: {{networkError, ""}}},
+			expectedCount: 0,
+			expectedErr:   true,
+		},
+		{
+			name:          "Unknown user",
+			responses:     map[string][]fakeResponse{usersPath: {{200, `{"data":[]}`}}, followersPath: {{200, `{"total":42,"data":[]}`}}},
+			expectedCount: 0,
+			expectedErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Settings{UserToken: true, Users: []TwitchUser{{Name: "cool4pso", AccessToken: "token"}}}
+			e, _ := newTestExporter(t, tt.responses, s)
+
+			count, err := e.followerCount(e.sessions[0])
+			if count != tt.expectedCount {
+				t.Errorf("expected follower count: %v, got: %v", tt.expectedCount, count)
+			}
+
+			if (err != nil) != tt.expectedErr {
+				t.Errorf("expected error: %v, got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := retryPolicy{attempts: 10, initialDelay: time.Second, maxDelay: 10 * time.Second}
+	for retry := 0; retry < 70; retry++ {
+		if d := p.delay(retry); d < 0 || d > p.maxDelay {
+			t.Fatalf("retry %v: expected delay between 0 and %v, got: %v", retry, p.maxDelay, d)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package collectors
+
+import (
+	"fmt"
+	"time"
+
+	helix "github.com/nicklaw5/helix/v2"
+)
+
+// TwitchUser is a broadcaster the exporter is authorized for, with its own
+// token pair, used to collect the user level metrics
+type TwitchUser struct {
+	Name             string
+	AccessToken      string
+	RefreshToken     string
+	AccessTokenFile  string
+	RefreshTokenFile string
+}
+
+// Token and authorization state of an authenticated user. Each user has its
+// own client holding the user token.
+type userSession struct {
+	name             string
+	client           *helix.Client
+	refreshToken     string
+	accessTokenFile  secretFile
+	refreshTokenFile secretFile
+	token            tokenHealth
+	grantedScopes    map[string]bool
+	authorizationURL string
+}
+
+// UserStatus describes the authorization state of a user, used by the root page
+type UserStatus struct {
+	Name             string
+	AuthorizationURL string
+	Authorized       bool
+	Valid            bool
+	ExpiresAt        time.Time
+}
+
+func newUserSession(s *Settings, user TwitchUser, authClient *helix.Client) (*userSession, error) {
+	options := s.ApiSettings.Options
+	options.AppAccessToken = ""
+	options.UserAccessToken = user.AccessToken
+	// The refresh token is managed by the exporter, otherwise helix silently
+	// refreshes tokens during scrapes
+	options.RefreshToken = ""
+
+	client, err := helix.NewClient(&options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userSession{
+		name:             user.Name,
+		client:           client,
+		refreshToken:     user.RefreshToken,
+		accessTokenFile:  secretFile{path: user.AccessTokenFile, value: user.AccessToken},
+		refreshTokenFile: secretFile{path: user.RefreshTokenFile, value: user.RefreshToken},
+		authorizationURL: authorizationURL(authClient, user.Name, s.RequiredScopes(), len(s.Users) > 1),
+	}, nil
+}
+
+func authorizationURL(authClient *helix.Client, user string, scopes []string, forceVerify bool) string {
+	return authClient.GetAuthorizationURL(&helix.AuthorizationURLParams{
+		ResponseType: "code",
+		Scopes:       scopes,
+		State:        user,
+		// Lets the user pick the right twitch account when authorizing
+		// more than one user from the same browser
+		ForceVerify: forceVerify,
+	})
+}
+
+// Updates the authorization url of the user with the scopes required by the
+// enabled collectors
+func (e *Exporter) setAuthorizationURL(u *userSession) {
+	e.tokenMu.Lock()
+	url := authorizationURL(e.authClient, u.name, e.requiredScopes(), len(e.sessions) > 1)
+	e.tokenMu.Unlock()
+
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+
+	u.authorizationURL = url
+}
+
+func (e *Exporter) userSession(name string) (*userSession, error) {
+	for _, u := range e.sessions {
+		if u.name == name {
+			return u, nil
+		}
+	}
+
+	return nil, fmt.Errorf("User %v not configured", name)
+}
+
+// UserStatus returns the authorization state of every configured user
+func (e *Exporter) UserStatus() []UserStatus {
+	e.stateMu.RLock()
+	defer e.stateMu.RUnlock()
+
+	var status []UserStatus
+	for _, u := range e.sessions {
+		status = append(status, UserStatus{
+			Name:             u.name,
+			AuthorizationURL: u.authorizationURL,
+			Authorized:       u.client.GetUserAccessToken() != "",
+			Valid:            u.token.isValid(),
+			ExpiresAt:        u.token.expiresAt,
+		})
+	}
+
+	return status
+}
@@ -0,0 +1,136 @@
+package collectors
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// Used when the viewers window is not set
+	DefaultViewersWindow = 30 * time.Minute
+	// Used when the viewers minimum samples is not set
+	DefaultViewersMinSamples = 5
+	// Used when the viewers sample interval is not set
+	DefaultViewersInterval = time.Minute
+)
+
+// Viewers configures the rolling window of viewer counts the volatility and
+// anomaly score are computed over
+type Viewers struct {
+	Window time.Duration
+	// Minimum time between samples, more scrapes or Prometheus replicas
+	// don't add samples
+	Interval time.Duration
+	// Samples within the window required before the standard deviation and
+	// anomaly score are exported
+	MinSamples int
+}
+
+func (v *Viewers) window() time.Duration {
+	if v == nil || v.Window <= 0 {
+		return DefaultViewersWindow
+	}
+
+	return v.Window
+}
+
+func (v *Viewers) interval() time.Duration {
+	if v == nil || v.Interval <= 0 {
+		return DefaultViewersInterval
+	}
+
+	return v.Interval
+}
+
+func (v *Viewers) minSamples() int {
+	if v == nil || v.MinSamples <= 0 {
+		return DefaultViewersMinSamples
+	}
+
+	return v.MinSamples
+}
+
+type viewerSample struct {
+	at      time.Time
+	viewers float64
+}
+
+// Viewer counts of a live channel, one sample per refresh, oldest first
+type viewerWindow struct {
+	samples []viewerSample
+}
+
+// Adds the sample and drops the ones older than the window
+func (w *viewerWindow) add(at time.Time, viewers int, window time.Duration) {
+	w.samples = append(w.samples, viewerSample{at: at, viewers: float64(viewers)})
+
+	start := at.Add(-window)
+	for len(w.samples) > 0 && w.samples[0].at.Before(start) {
+		w.samples = w.samples[1:]
+	}
+}
+
+// Returns the time of the latest sample, zero without samples
+func (w *viewerWindow) latest() time.Time {
+	if len(w.samples) == 0 {
+		return time.Time{}
+	}
+
+	return w.samples[len(w.samples)-1].at
+}
+
+// Returns the viewer change since the previous sample, false with less than
+// two samples
+func (w *viewerWindow) change() (float64, bool) {
+	n := len(w.samples)
+	if n < 2 {
+		return 0, false
+	}
+
+	return w.samples[n-1].viewers - w.samples[n-2].viewers, true
+}
+
+// Returns the standard deviation of the samples, false with less than the
+// minimum samples
+func (w *viewerWindow) stddev(minSamples int) (float64, bool) {
+	if len(w.samples) < minSamples {
+		return 0, false
+	}
+
+	_, stddev := meanStddev(w.samples)
+	return stddev, true
+}
+
+// Returns the z-score of the latest sample against the samples before it,
+// false with less than the minimum samples before it. The standard deviation
+// is at least one viewer, a jump on a flat series scores its size instead of
+// an infinite score.
+func (w *viewerWindow) anomalyScore(minSamples int) (float64, bool) {
+	n := len(w.samples)
+	if n-1 < minSamples {
+		return 0, false
+	}
+
+	mean, stddev := meanStddev(w.samples[:n-1])
+	return (w.samples[n-1].viewers - mean) / math.Max(stddev, 1), true
+}
+
+// Returns the mean and population standard deviation of the viewer counts
+func meanStddev(samples []viewerSample) (float64, float64) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+
+	var sum float64
+	for _, s := range samples {
+		sum += s.viewers
+	}
+	mean := sum / float64(len(samples))
+
+	var squares float64
+	for _, s := range samples {
+		squares += (s.viewers - mean) * (s.viewers - mean)
+	}
+
+	return mean, math.Sqrt(squares / float64(len(samples)))
+}
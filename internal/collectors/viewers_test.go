@@ -0,0 +1,236 @@
+package collectors
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestViewerWindow(t *testing.T) {
+	tests := []struct {
+		name           string
+		series         []int
+		window         time.Duration
+		minSamples     int
+		expectedChange float64
+		expectedStddev float64
+		expectedScore  float64
+		expectedOk     bool
+	}{
+		{
+			name:           "Steady audience",
+			series:         []int{100, 102, 98, 101, 99, 100},
+			window:         time.Hour,
+			minSamples:     5,
+			expectedChange: 1,
+			expectedStddev: 1.2910,
+			expectedScore:  0,
+			expectedOk:     true,
+		},
+		{
+			name:           "Viewbot jump on a flat series",
+			series:         []int{50, 50, 50, 50, 50, 650},
+			window:         time.Hour,
+			minSamples:     5,
+			expectedChange: 600,
+			expectedStddev: 223.6068,
+			expectedScore:  600,
+			expectedOk:     true,
+		},
+		{
+			name:           "Raid on a noisy series",
+			series:         []int{200, 220, 180, 210, 190, 200, 700},
+			window:         time.Hour,
+			minSamples:     5,
+			expectedChange: 500,
+			expectedStddev: 175.3713,
+			expectedScore:  38.7298,
+			expectedOk:     true,
+		},
+		{
+			name:       "Not enough samples",
+			series:     []int{10, 20, 30},
+			window:     time.Hour,
+			minSamples: 5,
+			// The change only needs two samples
+			expectedChange: 10,
+		},
+		{
+			name:           "Old samples leave the window",
+			series:         []int{1000, 1000, 1000, 10, 12, 8, 10, 11, 10},
+			window:         5 * time.Minute,
+			minSamples:     5,
+			expectedChange: -1,
+			expectedStddev: 1.2134,
+			expectedScore:  -0.1508,
+			expectedOk:     true,
+		},
+	}
+
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &viewerWindow{}
+			for i, viewers := range tt.series {
+				w.add(start.Add(time.Duration(i)*time.Minute), viewers, tt.window)
+			}
+
+			if change, _ := w.change(); change != tt.expectedChange {
+				t.Errorf("expected change %v, got %v", tt.expectedChange, change)
+			}
+
+			stddev, ok := w.stddev(tt.minSamples)
+			if ok != tt.expectedOk {
+				t.Fatalf("expected stddev %v, got %v", tt.expectedOk, ok)
+			}
+
+			score, ok := w.anomalyScore(tt.minSamples)
+			if ok != tt.expectedOk {
+				t.Fatalf("expected anomaly score %v, got %v", tt.expectedOk, ok)
+			}
+
+			if math.Abs(stddev-tt.expectedStddev) > 1e-3 {
+				t.Errorf("expected stddev %v, got %v", tt.expectedStddev, stddev)
+			}
+
+			if math.Abs(score-tt.expectedScore) > 1e-3 {
+				t.Errorf("expected anomaly score %v, got %v", tt.expectedScore, score)
+			}
+		})
+	}
+}
+
+func TestStreamCollectorViewerSignals(t *testing.T) {
+	e, _ := newTestExporter(t, map[string][]fakeResponse{
+		usersPath: {{200, `{"data":[{"id":"1","login":"cool4pso"}]}`}},
+		streamsPath: {
+			{200, `{"data":[{"user_id":"1","type":"live","viewer_count":10}]}`},
+			{200, `{"data":[{"user_id":"1","type":"live","viewer_count":12}]}`},
+			{200, `{"data":[{"user_id":"1","type":"live","viewer_count":40}]}`},
+			{200, `{"data":[]}`},
+			{200, `{"data":[{"user_id":"1","type":"live","viewer_count":5}]}`},
+		},
+	}, &Settings{
+		Channels: []TwitchChannel{{Name: "cool4pso"}},
+		Viewers:  &Viewers{Interval: time.Nanosecond, MinSamples: 2},
+	})
+
+	tests := []struct {
+		name            string
+		expectedMetrics map[string]float64
+		missingMetrics  []string
+	}{
+		{
+			name:           "First sample",
+			missingMetrics: []string{"twitch_viewer_change{cool4pso}", "twitch_viewer_stddev{cool4pso}", "twitch_viewer_anomaly_score{cool4pso}"},
+		},
+		{
+			name: "Second sample",
+			expectedMetrics: map[string]float64{
+				"twitch_viewer_change{cool4pso}": 2,
+				"twitch_viewer_stddev{cool4pso}": 1,
+			},
+			missingMetrics: []string{"twitch_viewer_anomaly_score{cool4pso}"},
+		},
+		{
+			name: "Jump",
+			expectedMetrics: map[string]float64{
+				"twitch_viewer_change{cool4pso}":        28,
+				"twitch_viewer_anomaly_score{cool4pso}": 29,
+			},
+		},
+		{
+			name:           "Offline",
+			missingMetrics: []string{"twitch_viewer_change{cool4pso}", "twitch_viewer_stddev{cool4pso}", "twitch_viewer_anomaly_score{cool4pso}"},
+		},
+		{
+			name:           "Next stream starts a new window",
+			missingMetrics: []string{"twitch_viewer_change{cool4pso}", "twitch_viewer_stddev{cool4pso}", "twitch_viewer_anomaly_score{cool4pso}"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics, err := updateMetrics(t, e.collectors["stream"], &scrape{channels: e.Settings.Channels})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			for name, value := range tt.expectedMetrics {
+				if got, ok := metrics[name]; !ok || got != value {
+					t.Errorf("expected %v %v, got %v", name, value, metrics)
+				}
+			}
+
+			for _, name := range tt.missingMetrics {
+				if _, ok := metrics[name]; ok {
+					t.Errorf("expected no %v metric", name)
+				}
+			}
+		})
+	}
+}
+
+func TestViewerWindowSamples(t *testing.T) {
+	tests := []struct {
+		name           string
+		interval       time.Duration
+		expectedChange float64
+		expectedOk     bool
+	}{
+		{
+			name:           "Probes don't add samples",
+			interval:       time.Nanosecond,
+			expectedChange: 4,
+			expectedOk:     true,
+		},
+		{
+			name:     "Scrapes within the interval don't add samples",
+			interval: time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestExporter(t, map[string][]fakeResponse{
+				usersPath: {{200, `{"data":[{"id":"1","login":"cool4pso"}]}`}},
+				streamsPath: {
+					{200, `{"data":[{"user_id":"1","type":"live","viewer_count":10}]}`},
+					{200, `{"data":[{"user_id":"1","type":"live","viewer_count":12}]}`},
+					{200, `{"data":[{"user_id":"1","type":"live","viewer_count":14}]}`},
+				},
+			}, &Settings{
+				Channels: []TwitchChannel{{Name: "cool4pso"}},
+				Modules:  map[string]ProbeModule{"stream": {Collectors: []string{"stream"}}},
+				Viewers:  &Viewers{Interval: tt.interval},
+			})
+
+			if _, err := updateMetrics(t, e.collectors["stream"], &scrape{channels: e.Settings.Channels}); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			probe, err := e.Probe("cool4pso", "stream")
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(probe)
+			if _, err := reg.Gather(); err != nil {
+				t.Fatalf("failed to gather metrics: %v", err)
+			}
+
+			metrics, err := updateMetrics(t, e.collectors["stream"], &scrape{channels: e.Settings.Channels})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			change, ok := metrics["twitch_viewer_change{cool4pso}"]
+			if ok != tt.expectedOk || change != tt.expectedChange {
+				t.Errorf("expected viewer change %v (%v), got %v (%v)", tt.expectedChange, tt.expectedOk, change, ok)
+			}
+		})
+	}
+}
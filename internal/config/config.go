@@ -0,0 +1,501 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coolapso/prometheus-twitch-exporter/internal/collectors"
+	"gopkg.in/yaml.v3"
+)
+
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Config is the configuration file format
+type Config struct {
+	LogLevel     string `yaml:"log_level"`
+	LogFormat    string `yaml:"log_format"`
+	MetricsPath  string `yaml:"metrics_path"`
+	ListenPort   string `yaml:"listen_port"`
+	Address      string `yaml:"address"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// Read instead of client_secret, and read again to pick up rotations
+	ClientSecretFile string            `yaml:"client_secret_file,omitempty"`
+	UserToken        bool              `yaml:"user_token"`
+	AdminToken       string            `yaml:"admin_token"`
+	StateDir         string            `yaml:"state_dir,omitempty"`
+	Collectors       map[string]bool   `yaml:"collectors,omitempty"`
+	Channels         []Channel         `yaml:"channels"`
+	Users            []User            `yaml:"users"`
+	Modules          map[string]Module `yaml:"modules,omitempty"`
+	Discovery        *Discovery        `yaml:"discovery,omitempty"`
+	Categories       *Categories       `yaml:"categories,omitempty"`
+	Content          *Content          `yaml:"content,omitempty"`
+	Viewers          *Viewers          `yaml:"viewers,omitempty"`
+
+	// Set with flags or environment variables, merged into the channels
+	// and users once resolved
+	channelNames     []string
+	userNames        []string
+	accessToken      string
+	accessTokenFile  string
+	refreshToken     string
+	refreshTokenFile string
+}
+
+// Channel to collect metrics from
+type Channel struct {
+	Name            string            `yaml:"name"`
+	Labels          map[string]string `yaml:"labels"`
+	Collectors      []string          `yaml:"collectors"`
+	RefreshInterval time.Duration     `yaml:"refresh_interval"`
+}
+
+// Module selects the collectors that run when probing a channel
+type Module struct {
+	Collectors []string `yaml:"collectors"`
+}
+
+// Discovery adds the channels found by its sources to the channels
+type Discovery struct {
+	Interval    time.Duration  `yaml:"interval"`
+	Follows     bool           `yaml:"follows"`
+	Teams       []string       `yaml:"teams"`
+	Games       DiscoveryGames `yaml:"games"`
+	Include     string         `yaml:"include"`
+	Exclude     string         `yaml:"exclude"`
+	MaxChannels int            `yaml:"max_channels"`
+}
+
+// DiscoveryGames discovers the top live streams of the games
+type DiscoveryGames struct {
+	IDs []string `yaml:"ids"`
+	Top int      `yaml:"top"`
+}
+
+// Categories are the games followed by the category collector
+type Categories struct {
+	Games      []Category    `yaml:"games"`
+	TopStreams int           `yaml:"top_streams,omitempty"`
+	TopGames   int           `yaml:"top_games,omitempty"`
+	Interval   time.Duration `yaml:"interval"`
+}
+
+// Category is a game, by name or id
+type Category struct {
+	Name string `yaml:"name,omitempty"`
+	ID   string `yaml:"id,omitempty"`
+}
+
+// Content configures how often and how far back clips and videos are read
+type Content struct {
+	Interval time.Duration `yaml:"interval"`
+	Lookback time.Duration `yaml:"lookback"`
+}
+
+// Viewers configures the rolling window of viewer counts of the stream
+// collector
+type Viewers struct {
+	Window     time.Duration `yaml:"window"`
+	Interval   time.Duration `yaml:"interval"`
+	MinSamples int           `yaml:"min_samples"`
+}
+
+// User the exporter is authorized for
+type User struct {
+	Name             string `yaml:"name"`
+	AccessToken      string `yaml:"access_token,omitempty"`
+	RefreshToken     string `yaml:"refresh_token,omitempty"`
+	AccessTokenFile  string `yaml:"access_token_file,omitempty"`
+	RefreshTokenFile string `yaml:"refresh_token_file,omitempty"`
+}
+
+// Parse decodes and validates the configuration, unknown fields are errors
+func Parse(data []byte) (*Config, error) {
+	c := &Config{}
+	if err := decode(c, data); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Decodes the configuration over the values already set in c
+func decode(c *Config, data []byte) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return c.validate(&root)
+}
+
+// Returns the line of each item of the sequence under the top level key
+func itemLines(root *yaml.Node, key string) []int {
+	if len(root.Content) == 0 {
+		return nil
+	}
+
+	doc := root.Content[0]
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value != key {
+			continue
+		}
+
+		var lines []int
+		for _, item := range doc.Content[i+1].Content {
+			lines = append(lines, item.Line)
+		}
+		return lines
+	}
+
+	return nil
+}
+
+// Returns the line of each key of the mapping under the top level key
+func mapKeyLines(root *yaml.Node, key string) map[string]int {
+	lines := make(map[string]int)
+	if len(root.Content) == 0 {
+		return lines
+	}
+
+	doc := root.Content[0]
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value != key {
+			continue
+		}
+
+		mapping := doc.Content[i+1].Content
+		for j := 0; j+1 < len(mapping); j += 2 {
+			lines[mapping[j].Value] = mapping[j].Line
+		}
+	}
+
+	return lines
+}
+
+// Returns the line of the top level key
+func keyLine(root *yaml.Node, key string) int {
+	if len(root.Content) == 0 {
+		return 0
+	}
+
+	doc := root.Content[0]
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value == key {
+			return doc.Content[i].Line
+		}
+	}
+
+	return 0
+}
+
+func lineErr(line int, format string, args ...any) error {
+	return fmt.Errorf("line %v: %v", line, fmt.Sprintf(format, args...))
+}
+
+func (c *Config) validate(root *yaml.Node) error {
+	var errs []error
+
+	if c.ClientSecret != "" && c.ClientSecretFile != "" {
+		errs = append(errs, lineErr(keyLine(root, "client_secret_file"), "client_secret and client_secret_file can't be used together"))
+	}
+
+	for name := range c.Collectors {
+		if !slices.Contains(collectors.Collectors(), name) {
+			errs = append(errs, lineErr(keyLine(root, "collectors"), "unknown collector %q, valid collectors are: %v", name, strings.Join(collectors.Collectors(), ", ")))
+		}
+	}
+
+	lines := itemLines(root, "channels")
+	seen := make(map[string]bool)
+	for i, ch := range c.Channels {
+		line := lines[i]
+		if ch.Name == "" {
+			errs = append(errs, lineErr(line, "channel name is required"))
+			continue
+		}
+
+		if seen[ch.Name] {
+			errs = append(errs, lineErr(line, "channel %v defined more than once", ch.Name))
+		}
+		seen[ch.Name] = true
+
+		for label := range ch.Labels {
+			if !labelNameRE.MatchString(label) || strings.HasPrefix(label, "__") {
+				errs = append(errs, lineErr(line, "channel %v: invalid label name %q", ch.Name, label))
+			}
+
+			if label == "name" {
+				errs = append(errs, lineErr(line, "channel %v: label name is reserved for the channel name", ch.Name))
+			} else if slices.Contains(collectors.ReservedLabels(), label) {
+				errs = append(errs, lineErr(line, "channel %v: label %v is reserved for the channel metrics, reserved labels are: %v", ch.Name, label, strings.Join(collectors.ReservedLabels(), ", ")))
+			}
+		}
+
+		for _, collector := range ch.Collectors {
+			if !slices.Contains(collectors.ChannelCollectors(), collector) {
+				errs = append(errs, lineErr(line, "channel %v: unknown collector %q, valid collectors are: %v", ch.Name, collector, strings.Join(collectors.ChannelCollectors(), ", ")))
+			}
+		}
+
+		if ch.RefreshInterval < 0 {
+			errs = append(errs, lineErr(line, "channel %v: refresh_interval can't be negative", ch.Name))
+		}
+	}
+
+	moduleLines := mapKeyLines(root, "modules")
+	for name, m := range c.Modules {
+		if len(m.Collectors) == 0 {
+			errs = append(errs, lineErr(moduleLines[name], "module %v: at least one collector is required", name))
+		}
+
+		for _, collector := range m.Collectors {
+			if !slices.Contains(collectors.ChannelCollectors(), collector) {
+				errs = append(errs, lineErr(moduleLines[name], "module %v: unknown collector %q, valid collectors are: %v", name, collector, strings.Join(collectors.ChannelCollectors(), ", ")))
+			}
+		}
+	}
+
+	if c.Discovery != nil {
+		errs = append(errs, c.Discovery.validate(mapKeyLines(root, "discovery"))...)
+	}
+
+	if c.Categories != nil {
+		errs = append(errs, c.Categories.validate(root)...)
+	}
+
+	if c.Content != nil {
+		lines := mapKeyLines(root, "content")
+		if c.Content.Interval < 0 {
+			errs = append(errs, lineErr(lines["interval"], "content: interval can't be negative"))
+		}
+
+		if c.Content.Lookback < 0 {
+			errs = append(errs, lineErr(lines["lookback"], "content: lookback can't be negative"))
+		}
+	}
+
+	if c.Viewers != nil {
+		lines := mapKeyLines(root, "viewers")
+		if c.Viewers.Window < 0 {
+			errs = append(errs, lineErr(lines["window"], "viewers: window can't be negative"))
+		}
+
+		if c.Viewers.Interval < 0 {
+			errs = append(errs, lineErr(lines["interval"], "viewers: interval can't be negative"))
+		}
+
+		if c.Viewers.MinSamples < 0 {
+			errs = append(errs, lineErr(lines["min_samples"], "viewers: min_samples can't be negative"))
+		}
+	}
+
+	lines = itemLines(root, "users")
+	seen = make(map[string]bool)
+	for i, u := range c.Users {
+		line := lines[i]
+		if u.Name == "" {
+			errs = append(errs, lineErr(line, "user name is required"))
+			continue
+		}
+
+		if seen[u.Name] {
+			errs = append(errs, lineErr(line, "user %v defined more than once", u.Name))
+		}
+		seen[u.Name] = true
+
+		if u.AccessToken != "" && u.AccessTokenFile != "" {
+			errs = append(errs, lineErr(line, "user %v: access_token and access_token_file can't be used together", u.Name))
+		}
+
+		if u.RefreshToken != "" && u.RefreshTokenFile != "" {
+			errs = append(errs, lineErr(line, "user %v: refresh_token and refresh_token_file can't be used together", u.Name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (d *Discovery) validate(lines map[string]int) []error {
+	var errs []error
+	if d.Interval < 0 {
+		errs = append(errs, lineErr(lines["interval"], "discovery: interval can't be negative"))
+	}
+
+	for _, f := range []struct{ key, expr string }{{"include", d.Include}, {"exclude", d.Exclude}} {
+		if _, err := regexp.Compile(f.expr); err != nil {
+			errs = append(errs, lineErr(lines[f.key], "discovery: invalid %v expression: %v", f.key, err))
+		}
+	}
+
+	if d.Games.Top < 0 {
+		errs = append(errs, lineErr(lines["games"], "discovery: games top can't be negative"))
+	}
+
+	if d.MaxChannels < 0 {
+		errs = append(errs, lineErr(lines["max_channels"], "discovery: max_channels can't be negative"))
+	}
+
+	return errs
+}
+
+func (c *Categories) validate(root *yaml.Node) []error {
+	var errs []error
+	line := keyLine(root, "categories")
+	if len(c.Games) == 0 {
+		errs = append(errs, lineErr(line, "categories: at least one game is required"))
+	}
+
+	for _, g := range c.Games {
+		if (g.Name == "") == (g.ID == "") {
+			errs = append(errs, lineErr(line, "categories: each game needs either a name or an id"))
+		}
+	}
+
+	if c.TopStreams < 0 || c.TopGames < 0 {
+		errs = append(errs, lineErr(line, "categories: top_streams and top_games can't be negative"))
+	}
+
+	if c.Interval < 0 {
+		errs = append(errs, lineErr(line, "categories: interval can't be negative"))
+	}
+
+	return errs
+}
+
+// MarshalYAML writes the interval as a duration string
+func (d Discovery) MarshalYAML() (any, error) {
+	type discovery struct {
+		Interval    string         `yaml:"interval,omitempty"`
+		Follows     bool           `yaml:"follows,omitempty"`
+		Teams       []string       `yaml:"teams,omitempty"`
+		Games       DiscoveryGames `yaml:"games,omitempty"`
+		Include     string         `yaml:"include,omitempty"`
+		Exclude     string         `yaml:"exclude,omitempty"`
+		MaxChannels int            `yaml:"max_channels,omitempty"`
+	}
+
+	r := discovery{
+		Follows:     d.Follows,
+		Teams:       d.Teams,
+		Games:       d.Games,
+		Include:     d.Include,
+		Exclude:     d.Exclude,
+		MaxChannels: d.MaxChannels,
+	}
+	if d.Interval > 0 {
+		r.Interval = d.Interval.String()
+	}
+
+	return r, nil
+}
+
+// MarshalYAML writes the interval as a duration string
+func (c Categories) MarshalYAML() (any, error) {
+	type categories struct {
+		Games      []Category `yaml:"games"`
+		TopStreams int        `yaml:"top_streams,omitempty"`
+		TopGames   int        `yaml:"top_games,omitempty"`
+		Interval   string     `yaml:"interval,omitempty"`
+	}
+
+	r := categories{Games: c.Games, TopStreams: c.TopStreams, TopGames: c.TopGames}
+	if c.Interval > 0 {
+		r.Interval = c.Interval.String()
+	}
+
+	return r, nil
+}
+
+// MarshalYAML writes the interval and lookback as duration strings
+func (c Content) MarshalYAML() (any, error) {
+	type content struct {
+		Interval string `yaml:"interval,omitempty"`
+		Lookback string `yaml:"lookback,omitempty"`
+	}
+
+	var r content
+	if c.Interval > 0 {
+		r.Interval = c.Interval.String()
+	}
+	if c.Lookback > 0 {
+		r.Lookback = c.Lookback.String()
+	}
+
+	return r, nil
+}
+
+// MarshalYAML writes the window and interval as duration strings
+func (v Viewers) MarshalYAML() (any, error) {
+	type viewers struct {
+		Window     string `yaml:"window,omitempty"`
+		Interval   string `yaml:"interval,omitempty"`
+		MinSamples int    `yaml:"min_samples,omitempty"`
+	}
+
+	r := viewers{MinSamples: v.MinSamples}
+	if v.Window > 0 {
+		r.Window = v.Window.String()
+	}
+	if v.Interval > 0 {
+		r.Interval = v.Interval.String()
+	}
+
+	return r, nil
+}
+
+// Shown instead of the secrets when printing the configuration
+const redacted = "<redacted>"
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	return redacted
+}
+
+// Redacted returns a copy of the configuration with the secrets redacted
+func (c *Config) Redacted() *Config {
+	r := *c
+	r.ClientSecret = redact(c.ClientSecret)
+	r.AdminToken = redact(c.AdminToken)
+	r.Users = nil
+	for _, u := range c.Users {
+		u.AccessToken = redact(u.AccessToken)
+		u.RefreshToken = redact(u.RefreshToken)
+		r.Users = append(r.Users, u)
+	}
+
+	return &r
+}
+
+// MarshalYAML writes the refresh interval as a duration string
+func (c Channel) MarshalYAML() (any, error) {
+	type channel struct {
+		Name            string            `yaml:"name"`
+		Labels          map[string]string `yaml:"labels,omitempty"`
+		Collectors      []string          `yaml:"collectors,omitempty"`
+		RefreshInterval string            `yaml:"refresh_interval,omitempty"`
+	}
+
+	ch := channel{Name: c.Name, Labels: c.Labels, Collectors: c.Collectors}
+	if c.RefreshInterval > 0 {
+		ch.RefreshInterval = c.RefreshInterval.String()
+	}
+
+	return ch, nil
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		expectedErr string
+	}{
+		{
+			name: "Valid configuration",
+			data: `
+client_id: id
+collectors:
+  followers: false
+channels:
+  - name: cool4pso
+    labels:
+      team: coolapso
+    collectors: [stream]
+    refresh_interval: 1m
+users:
+  - name: cool4pso
+    access_token: token
+`,
+		},
+		{
+			name:        "Empty configuration",
+			data:        "",
+			expectedErr: "",
+		},
+		{
+			name: "Unknown field",
+			data: `
+channels:
+  - name: cool4pso
+    refresh: 1m
+`,
+			expectedErr: "line 4: field refresh not found",
+		},
+		{
+			name: "Duplicated channel",
+			data: `
+channels:
+  - name: cool4pso
+  - name: cool4pso
+`,
+			expectedErr: "line 4: channel cool4pso defined more than once",
+		},
+		{
+			name: "Missing channel name",
+			data: `
+channels:
+  - labels:
+      team: coolapso
+`,
+			expectedErr: "line 3: channel name is required",
+		},
+		{
+			name: "Invalid label name",
+			data: `
+channels:
+  - name: cool4pso
+    labels:
+      1team: coolapso
+`,
+			expectedErr: `line 3: channel cool4pso: invalid label name "1team"`,
+		},
+		{
+			name: "Reserved label name",
+			data: `
+channels:
+  - name: cool4pso
+    labels:
+      name: coolapso
+`,
+			expectedErr: "line 3: channel cool4pso: label name is reserved for the channel name",
+		},
+		{
+			name: "Label of the channel metrics",
+			data: `
+channels:
+  - name: cool4pso
+    labels:
+      language: en
+`,
+			expectedErr: "line 3: channel cool4pso: label language is reserved for the channel metrics",
+		},
+		{
+			name: "Unknown channel collector",
+			data: `
+channels:
+  - name: cool4pso
+    collectors: [streams]
+`,
+			expectedErr: `line 3: channel cool4pso: unknown collector "streams"`,
+		},
+		{
+			name: "Unknown user collector",
+			data: `
+collectors:
+  subs: true
+`,
+			expectedErr: `line 2: unknown collector "subs"`,
+		},
+		{
+			name: "Negative refresh interval",
+			data: `
+channels:
+  - name: cool4pso
+    refresh_interval: -1m
+`,
+			expectedErr: "line 3: channel cool4pso: refresh_interval can't be negative",
+		},
+		{
+			name: "Negative viewers min samples",
+			data: `
+viewers:
+  window: 30m
+  min_samples: -1
+`,
+			expectedErr: "line 4: viewers: min_samples can't be negative",
+		},
+		{
+			name: "Negative viewers interval",
+			data: `
+viewers:
+  interval: -1m
+`,
+			expectedErr: "line 3: viewers: interval can't be negative",
+		},
+		{
+			name: "Negative categories interval",
+			data: `
+categories:
+  interval: -1m
+  games:
+    - name: Just Chatting
+`,
+			expectedErr: "line 2: categories: interval can't be negative",
+		},
+		{
+			name: "Unknown module collector",
+			data: `
+modules:
+  basic:
+    collectors: [followers]
+`,
+			expectedErr: `line 3: module basic: unknown collector "followers"`,
+		},
+		{
+			name: "Invalid discovery expression",
+			data: `
+discovery:
+  interval: 10m
+  games:
+    ids: ["509658"]
+  exclude: "(bot"
+`,
+			expectedErr: "line 6: discovery: invalid exclude expression",
+		},
+		{
+			name: "Duplicated user",
+			data: `
+users:
+  - name: cool4pso
+  - name: cool4pso
+`,
+			expectedErr: "line 4: user cool4pso defined more than once",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Fatalf("expected error %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestParseChannel(t *testing.T) {
+	c, err := Parse([]byte(`
+channels:
+  - name: cool4pso
+    labels:
+      team: coolapso
+    refresh_interval: 90s
+`))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	ch := c.Channels[0]
+	if ch.Labels["team"] != "coolapso" {
+		t.Errorf("expected label team coolapso, got %v", ch.Labels["team"])
+	}
+
+	if ch.RefreshInterval != 90*time.Second {
+		t.Errorf("expected refresh interval 90s, got %v", ch.RefreshInterval)
+	}
+}
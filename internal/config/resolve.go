@@ -0,0 +1,430 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/coolapso/prometheus-twitch-exporter/internal/collectors"
+	helix "github.com/nicklaw5/helix/v2"
+	"github.com/spf13/pflag"
+)
+
+const (
+	DefaultLogLevel    = "info"
+	DefaultLogFormat   = "text"
+	DefaultMetricsPath = "/metrics"
+	DefaultListenPort  = "9184"
+	DefaultAddress     = "localhost"
+
+	configFileFlag = "config.file"
+	configFileEnv  = "CONFIG_FILE"
+)
+
+// LookupEnv returns the value of an environment variable and if it is set
+type LookupEnv func(key string) (string, bool)
+
+// A setting that can be set with a flag and an environment variable
+type option struct {
+	flag string
+	// Flag setting the option to false, optional
+	noFlag   string
+	env      string
+	register func(fs *pflag.FlagSet)
+	fromFlag func(c *Config, fs *pflag.FlagSet) error
+	fromEnv  func(c *Config, value string) error
+}
+
+func stringOption(flag, env, value, usage string, field func(c *Config) *string) option {
+	return option{
+		flag: flag,
+		env:  env,
+		register: func(fs *pflag.FlagSet) {
+			fs.String(flag, value, usage)
+		},
+		fromFlag: func(c *Config, fs *pflag.FlagSet) error {
+			v, err := fs.GetString(flag)
+			*field(c) = v
+			return err
+		},
+		fromEnv: func(c *Config, value string) error {
+			*field(c) = value
+			return nil
+		},
+	}
+}
+
+func boolOption(flag, env string, value bool, usage string, field func(c *Config) *bool) option {
+	return option{
+		flag: flag,
+		env:  env,
+		register: func(fs *pflag.FlagSet) {
+			fs.Bool(flag, value, usage)
+		},
+		fromFlag: func(c *Config, fs *pflag.FlagSet) error {
+			v, err := fs.GetBool(flag)
+			*field(c) = v
+			return err
+		},
+		fromEnv: func(c *Config, value string) error {
+			v, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("Invalid value %q for %v, expected true or false", value, env)
+			}
+			*field(c) = v
+			return nil
+		},
+	}
+}
+
+// List values are separated by commas in flags, and by commas or spaces in
+// environment variables
+func sliceOption(flag, env, usage string, field func(c *Config) *[]string) option {
+	return option{
+		flag: flag,
+		env:  env,
+		register: func(fs *pflag.FlagSet) {
+			fs.StringSlice(flag, nil, usage)
+		},
+		fromFlag: func(c *Config, fs *pflag.FlagSet) error {
+			v, err := fs.GetStringSlice(flag)
+			*field(c) = v
+			return err
+		},
+		fromEnv: func(c *Config, value string) error {
+			*field(c) = strings.FieldsFunc(value, func(r rune) bool {
+				return r == ',' || r == ' '
+			})
+			return nil
+		},
+	}
+}
+
+// Setting the option clears the other one, used by the secrets that can be
+// given as a value or as a file
+func exclusive(o option, other func(c *Config) *string) option {
+	fromFlag, fromEnv := o.fromFlag, o.fromEnv
+	o.fromFlag = func(c *Config, fs *pflag.FlagSet) error {
+		*other(c) = ""
+		return fromFlag(c, fs)
+	}
+	o.fromEnv = func(c *Config, value string) error {
+		*other(c) = ""
+		return fromEnv(c, value)
+	}
+
+	return o
+}
+
+// Collectors are enabled with --collector.<name> and disabled with
+// --no-collector.<name>, the latter taking precedence
+func collectorOption(name string) option {
+	flag := "collector." + name
+	noFlag := "no-collector." + name
+	env := "COLLECTOR_" + strings.ToUpper(name)
+	set := func(c *Config, enabled bool) {
+		if c.Collectors == nil {
+			c.Collectors = make(map[string]bool)
+		}
+		c.Collectors[name] = enabled
+	}
+
+	return option{
+		flag:   flag,
+		noFlag: noFlag,
+		env:    env,
+		register: func(fs *pflag.FlagSet) {
+			fs.Bool(flag, collectors.CollectorDefault(name), fmt.Sprintf("Enable the %v collector", name))
+			fs.Bool(noFlag, false, fmt.Sprintf("Disable the %v collector", name))
+		},
+		fromFlag: func(c *Config, fs *pflag.FlagSet) error {
+			if fs.Changed(noFlag) {
+				disabled, err := fs.GetBool(noFlag)
+				set(c, !disabled)
+				return err
+			}
+
+			v, err := fs.GetBool(flag)
+			set(c, v)
+			return err
+		},
+		fromEnv: func(c *Config, value string) error {
+			v, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("Invalid value %q for %v, expected true or false", value, env)
+			}
+			set(c, v)
+			return nil
+		},
+	}
+}
+
+func clientSecret(c *Config) *string     { return &c.ClientSecret }
+func clientSecretFile(c *Config) *string { return &c.ClientSecretFile }
+func accessToken(c *Config) *string      { return &c.accessToken }
+func accessTokenFile(c *Config) *string  { return &c.accessTokenFile }
+func refreshToken(c *Config) *string     { return &c.refreshToken }
+func refreshTokenFile(c *Config) *string { return &c.refreshTokenFile }
+
+func options() []option {
+	opts := []option{
+		stringOption("log.level", "LOG_LEVEL", DefaultLogLevel, "Exporter log level", func(c *Config) *string { return &c.LogLevel }),
+		stringOption("log.format", "LOG_FORMAT", DefaultLogFormat, "Exporter log format, text or json", func(c *Config) *string { return &c.LogFormat }),
+		stringOption("metrics.path", "METRICS_PATH", DefaultMetricsPath, "Path to expose metrics at", func(c *Config) *string { return &c.MetricsPath }),
+		stringOption("listen.port", "LISTEN_PORT", DefaultListenPort, "Port to listen at", func(c *Config) *string { return &c.ListenPort }),
+		stringOption("address", "ADDRESS", DefaultAddress, "The address to access the exporter used for oauth redirect uri", func(c *Config) *string { return &c.Address }),
+		sliceOption("twitch.channels", "TWITCH_CHANNELS", "List of channels to get basic metrics from", func(c *Config) *[]string { return &c.channelNames }),
+		sliceOption("twitch.user", "TWITCH_USER", "List of users to authorize and get extra metrics from, the provided tokens belong to the first user", func(c *Config) *[]string { return &c.userNames }),
+		boolOption("user.token", "TWITCH_USER_TOKEN", false, "If going to use the provided token as a user token", func(c *Config) *bool { return &c.UserToken }),
+		stringOption("client.id", "TWITCH_CLIENT_ID", "", "twitch client id", func(c *Config) *string { return &c.ClientID }),
+		exclusive(stringOption("client.secret", "TWITCH_CLIENT_SECRET", "", "twitch client secret", clientSecret), clientSecretFile),
+		exclusive(stringOption("client.secret.file", "TWITCH_CLIENT_SECRET_FILE", "", "File to read the twitch client secret from, read again to pick up rotated secrets", clientSecretFile), clientSecret),
+		exclusive(stringOption("access.token", "TWITCH_ACCESS_TOKEN", "", "twitch user access token", accessToken), accessTokenFile),
+		exclusive(stringOption("access.token.file", "TWITCH_ACCESS_TOKEN_FILE", "", "File to read the twitch user access token from, read again to pick up rotated tokens", accessTokenFile), accessToken),
+		exclusive(stringOption("refresh.token", "TWITCH_REFRESH_TOKEN", "", "twitch refresh token", refreshToken), refreshTokenFile),
+		exclusive(stringOption("refresh.token.file", "TWITCH_REFRESH_TOKEN_FILE", "", "File to read the twitch refresh token from, read again to pick up rotated tokens", refreshTokenFile), refreshToken),
+		stringOption("admin.token", "ADMIN_TOKEN", "", "Bearer token required by the /admin endpoints and /-/reload, admin endpoints are disabled when empty", func(c *Config) *string { return &c.AdminToken }),
+		stringOption("state.dir", "STATE_DIR", "", "Directory where collectors keep state across restarts, like the previous subscriptions snapshot", func(c *Config) *string { return &c.StateDir }),
+	}
+
+	for _, name := range collectors.Collectors() {
+		opts = append(opts, collectorOption(name))
+	}
+
+	return opts
+}
+
+// RegisterFlags adds the flags of every setting to the flag set
+func RegisterFlags(fs *pflag.FlagSet) {
+	fs.String(configFileFlag, "", "Path to the YAML configuration file, environment variables and flags take precedence over it")
+	for _, o := range options() {
+		o.register(fs)
+	}
+}
+
+// Default returns the configuration used when nothing else is set
+func Default() *Config {
+	return &Config{
+		LogLevel:    DefaultLogLevel,
+		LogFormat:   DefaultLogFormat,
+		MetricsPath: DefaultMetricsPath,
+		ListenPort:  DefaultListenPort,
+		Address:     DefaultAddress,
+	}
+}
+
+// Resolve builds the configuration from the defaults, the configuration file,
+// the environment variables and the flags, each one taking precedence over
+// the previous ones. The flags must be registered with RegisterFlags.
+func Resolve(fs *pflag.FlagSet, lookupEnv LookupEnv) (*Config, error) {
+	c := Default()
+
+	path, _ := lookupEnv(configFileEnv)
+	if fs.Changed(configFileFlag) {
+		path, _ = fs.GetString(configFileFlag)
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := decode(c, data); err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+	}
+
+	for _, o := range options() {
+		if value, ok := lookupEnv(o.env); ok {
+			if err := o.fromEnv(c, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, o := range options() {
+		if fs.Changed(o.flag) || (o.noFlag != "" && fs.Changed(o.noFlag)) {
+			if err := o.fromFlag(c, fs); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	c.merge()
+	if err := c.readSecretFiles(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Reads the secrets given as files
+func (c *Config) readSecretFiles() error {
+	read := func(path string, secret *string) error {
+		if path == "" {
+			return nil
+		}
+
+		value, err := collectors.ReadSecretFile(path)
+		*secret = value
+		return err
+	}
+
+	if err := read(c.ClientSecretFile, &c.ClientSecret); err != nil {
+		return err
+	}
+
+	for i := range c.Users {
+		u := &c.Users[i]
+		if err := read(u.AccessTokenFile, &u.AccessToken); err != nil {
+			return err
+		}
+
+		if err := read(u.RefreshTokenFile, &u.RefreshToken); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Adds the channels and users set with flags or environment variables to the
+// ones from the configuration file. The provided tokens belong to the first
+// user and take precedence over the ones in the configuration file.
+func (c *Config) merge() {
+	for _, name := range c.userNames {
+		if !slices.ContainsFunc(c.Users, func(u User) bool { return u.Name == name }) {
+			c.Users = append(c.Users, User{Name: name})
+		}
+	}
+
+	if len(c.Users) > 0 {
+		first := 0
+		if len(c.userNames) > 0 {
+			first = slices.IndexFunc(c.Users, func(u User) bool { return u.Name == c.userNames[0] })
+		}
+
+		u := &c.Users[first]
+		if c.accessToken != "" || c.accessTokenFile != "" {
+			u.AccessToken, u.AccessTokenFile = c.accessToken, c.accessTokenFile
+		}
+
+		if c.refreshToken != "" || c.refreshTokenFile != "" {
+			u.RefreshToken, u.RefreshTokenFile = c.refreshToken, c.refreshTokenFile
+		}
+	}
+
+	// Users are also monitored as channels, otherwise only user metrics
+	// would be collected
+	names := slices.Clone(c.channelNames)
+	for _, u := range c.Users {
+		names = append(names, u.Name)
+	}
+
+	for _, name := range names {
+		if !slices.ContainsFunc(c.Channels, func(ch Channel) bool { return ch.Name == name }) {
+			c.Channels = append(c.Channels, Channel{Name: name})
+		}
+	}
+
+	c.channelNames, c.userNames = nil, nil
+	c.accessToken, c.accessTokenFile = "", ""
+	c.refreshToken, c.refreshTokenFile = "", ""
+}
+
+// Settings returns the exporter settings
+func (c *Config) Settings() *collectors.Settings {
+	s := &collectors.Settings{
+		ApiSettings: collectors.ApiSettings{
+			Options: helix.Options{
+				ClientID:     c.ClientID,
+				ClientSecret: c.ClientSecret,
+				RedirectURI:  "http://" + c.Address + ":" + c.ListenPort,
+			},
+		},
+		ClientSecretFile: c.ClientSecretFile,
+		UserToken:        c.UserToken,
+		LogLevel:         c.LogLevel,
+		LogFormat:        c.LogFormat,
+		MetricsPath:      c.MetricsPath,
+		ListenPort:       c.ListenPort,
+		Address:          c.Address,
+		AdminToken:       c.AdminToken,
+		StateDir:         c.StateDir,
+		Collectors:       make(map[string]bool),
+	}
+
+	for name, enabled := range c.Collectors {
+		s.Collectors[name] = enabled
+	}
+
+	if len(c.Modules) > 0 {
+		s.Modules = make(map[string]collectors.ProbeModule)
+	}
+	for name, m := range c.Modules {
+		s.Modules[name] = collectors.ProbeModule{Collectors: m.Collectors}
+	}
+
+	if d := c.Discovery; d != nil {
+		s.Discovery = &collectors.Discovery{
+			Interval:    d.Interval,
+			Follows:     d.Follows,
+			Teams:       d.Teams,
+			GameIDs:     d.Games.IDs,
+			TopStreams:  d.Games.Top,
+			MaxChannels: d.MaxChannels,
+		}
+
+		// The expressions were validated when parsing the configuration
+		if d.Include != "" {
+			s.Discovery.Include = regexp.MustCompile(d.Include)
+		}
+		if d.Exclude != "" {
+			s.Discovery.Exclude = regexp.MustCompile(d.Exclude)
+		}
+	}
+
+	if c.Categories != nil {
+		s.Categories = &collectors.Categories{
+			TopStreams: c.Categories.TopStreams,
+			TopGames:   c.Categories.TopGames,
+			Interval:   c.Categories.Interval,
+		}
+		for _, g := range c.Categories.Games {
+			s.Categories.Games = append(s.Categories.Games, collectors.Category{Name: g.Name, ID: g.ID})
+		}
+	}
+
+	if c.Content != nil {
+		s.Content = &collectors.Content{Interval: c.Content.Interval, Lookback: c.Content.Lookback}
+	}
+
+	if c.Viewers != nil {
+		s.Viewers = &collectors.Viewers{Window: c.Viewers.Window, Interval: c.Viewers.Interval, MinSamples: c.Viewers.MinSamples}
+	}
+
+	for _, ch := range c.Channels {
+		s.Channels = append(s.Channels, collectors.TwitchChannel{
+			Name:            ch.Name,
+			Labels:          ch.Labels,
+			Collectors:      ch.Collectors,
+			RefreshInterval: ch.RefreshInterval,
+		})
+	}
+
+	for _, u := range c.Users {
+		s.Users = append(s.Users, collectors.TwitchUser{
+			Name:             u.Name,
+			AccessToken:      u.AccessToken,
+			RefreshToken:     u.RefreshToken,
+			AccessTokenFile:  u.AccessTokenFile,
+			RefreshTokenFile: u.RefreshTokenFile,
+		})
+	}
+
+	return s
+}
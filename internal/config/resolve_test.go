@@ -0,0 +1,283 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+const testFile = `
+log_level: warn
+client_id: fileID
+client_secret: fileSecret
+collectors:
+  followers: false
+channels:
+  - name: cool4pso
+    labels:
+      team: coolapso
+users:
+  - name: cool4pso
+    access_token: fileToken
+`
+
+func resolve(t *testing.T, file string, env map[string]string, args []string) *Config {
+	t.Helper()
+
+	if file != "" {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+		env["CONFIG_FILE"] = path
+	}
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("failed to parse flags: %v", err)
+	}
+
+	c, err := Resolve(fs, func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	return c
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	tests := []struct {
+		name             string
+		file             string
+		env              map[string]string
+		args             []string
+		expectedLevel    string
+		expectedClientID string
+		expectedSecret   string
+		expectedPort     string
+	}{
+		{
+			name:             "Defaults",
+			env:              map[string]string{},
+			expectedLevel:    DefaultLogLevel,
+			expectedClientID: "",
+			expectedPort:     DefaultListenPort,
+		},
+		{
+			name:             "File over defaults",
+			file:             testFile,
+			env:              map[string]string{},
+			expectedLevel:    "warn",
+			expectedClientID: "fileID",
+			expectedSecret:   "fileSecret",
+			expectedPort:     DefaultListenPort,
+		},
+		{
+			name:             "Env over file",
+			file:             testFile,
+			env:              map[string]string{"LOG_LEVEL": "debug", "TWITCH_CLIENT_ID": "envID"},
+			expectedLevel:    "debug",
+			expectedClientID: "envID",
+			expectedSecret:   "fileSecret",
+			expectedPort:     DefaultListenPort,
+		},
+		{
+			name:             "Flags over env",
+			file:             testFile,
+			env:              map[string]string{"LOG_LEVEL": "debug", "TWITCH_CLIENT_ID": "envID", "LISTEN_PORT": "8080"},
+			args:             []string{"--client.id", "flagID", "--client.secret", "flagSecret"},
+			expectedLevel:    "debug",
+			expectedClientID: "flagID",
+			expectedSecret:   "flagSecret",
+			expectedPort:     "8080",
+		},
+		{
+			name:             "Flag default does not override env",
+			env:              map[string]string{"LISTEN_PORT": "8080"},
+			expectedLevel:    DefaultLogLevel,
+			expectedClientID: "",
+			expectedPort:     "8080",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := resolve(t, tt.file, tt.env, tt.args)
+
+			if c.LogLevel != tt.expectedLevel {
+				t.Errorf("expected log level %v, got %v", tt.expectedLevel, c.LogLevel)
+			}
+
+			if c.ClientID != tt.expectedClientID {
+				t.Errorf("expected client id %v, got %v", tt.expectedClientID, c.ClientID)
+			}
+
+			if c.ClientSecret != tt.expectedSecret {
+				t.Errorf("expected client secret %v, got %v", tt.expectedSecret, c.ClientSecret)
+			}
+
+			if c.ListenPort != tt.expectedPort {
+				t.Errorf("expected listen port %v, got %v", tt.expectedPort, c.ListenPort)
+			}
+		})
+	}
+}
+
+func TestResolveCollectors(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		args     []string
+		expected bool
+	}{
+		{
+			name:     "File",
+			env:      map[string]string{},
+			expected: false,
+		},
+		{
+			name:     "Env over file",
+			env:      map[string]string{"COLLECTOR_FOLLOWERS": "true"},
+			expected: true,
+		},
+		{
+			name:     "Flag over env",
+			env:      map[string]string{"COLLECTOR_FOLLOWERS": "true"},
+			args:     []string{"--collector.followers=false"},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := resolve(t, testFile, tt.env, tt.args).Settings()
+
+			if enabled := s.CollectorEnabled("followers"); enabled != tt.expected {
+				t.Errorf("expected followers collector enabled %v, got %v", tt.expected, enabled)
+			}
+
+			if !s.CollectorEnabled("subscriptions") {
+				t.Errorf("expected subscriptions collector to be enabled")
+			}
+		})
+	}
+}
+
+func TestResolveTokens(t *testing.T) {
+	c := resolve(t, "", map[string]string{"TWITCH_ACCESS_TOKEN": "envToken"}, []string{
+		"--twitch.user", "cool4pso,coolapso",
+		"--refresh.token", "flagRefresh",
+	})
+
+	first := c.Users[0]
+	if first.Name != "cool4pso" || first.AccessToken != "envToken" || first.RefreshToken != "flagRefresh" {
+		t.Errorf("expected the tokens to belong to cool4pso, got %+v", first)
+	}
+
+	if c.ClientSecret != "" {
+		t.Errorf("expected tokens not to be set as the client secret, got %v", c.ClientSecret)
+	}
+
+	if c.Users[1].AccessToken != "" {
+		t.Errorf("expected coolapso to have no token, got %v", c.Users[1].AccessToken)
+	}
+}
+
+func TestResolveChannels(t *testing.T) {
+	c := resolve(t, testFile, map[string]string{"TWITCH_CHANNELS": "chan1 chan2,cool4pso"}, []string{"--twitch.user", "coolapso"})
+
+	var names []string
+	for _, ch := range c.Channels {
+		names = append(names, ch.Name)
+	}
+
+	expected := []string{"cool4pso", "chan1", "chan2", "coolapso"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected channels %v, got %v", expected, names)
+	}
+
+	if c.Channels[0].Labels["team"] != "coolapso" {
+		t.Errorf("expected file channel settings to be kept, got %+v", c.Channels[0])
+	}
+
+	if c.Users[0].AccessToken != "fileToken" {
+		t.Errorf("expected file user token to be kept, got %v", c.Users[0].AccessToken)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	c := resolve(t, testFile, map[string]string{"ADMIN_TOKEN": "admin"}, nil)
+	r := c.Redacted()
+
+	if r.ClientSecret != redacted || r.AdminToken != redacted || r.Users[0].AccessToken != redacted {
+		t.Errorf("expected secrets to be redacted, got %+v", r)
+	}
+
+	if r.Users[0].RefreshToken != "" {
+		t.Errorf("expected empty secrets to be left empty, got %v", r.Users[0].RefreshToken)
+	}
+
+	if c.ClientSecret != "fileSecret" || c.Users[0].AccessToken != "fileToken" {
+		t.Errorf("expected the original configuration to be unchanged")
+	}
+}
+
+func TestResolveSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	secretPath := filepath.Join(dir, "client_secret")
+	tokenPath := filepath.Join(dir, "access_token")
+	_ = os.WriteFile(secretPath, []byte("fileSecret\n"), 0o600)
+	_ = os.WriteFile(tokenPath, []byte("fileToken\n"), 0o600)
+
+	c := resolve(t, "", map[string]string{
+		"TWITCH_CLIENT_SECRET":     "envSecret",
+		"TWITCH_ACCESS_TOKEN_FILE": tokenPath,
+	}, []string{
+		"--client.secret.file", secretPath,
+		"--twitch.user", "cool4pso",
+	})
+
+	if c.ClientSecret != "fileSecret" {
+		t.Errorf("expected client secret from file, got %v", c.ClientSecret)
+	}
+
+	u := c.Users[0]
+	if u.AccessToken != "fileToken" || u.AccessTokenFile != tokenPath {
+		t.Errorf("expected access token from file, got %+v", u)
+	}
+
+	s := c.Settings()
+	if s.ClientSecretFile != secretPath || s.Users[0].AccessTokenFile != tokenPath {
+		t.Errorf("expected the secret files to be passed to the exporter, got %+v", s)
+	}
+}
+
+func TestParseExclusiveSecrets(t *testing.T) {
+	_, err := Parse([]byte(`
+client_secret: secret
+client_secret_file: /run/secrets/client_secret
+`))
+	expected := "line 3: client_secret and client_secret_file can't be used together"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
+
+func TestResolveNoCollector(t *testing.T) {
+	s := resolve(t, "", map[string]string{}, []string{"--collector.stream", "--no-collector.stream"}).Settings()
+	if s.CollectorEnabled("stream") {
+		t.Errorf("expected --no-collector.stream to disable the stream collector")
+	}
+
+	s = resolve(t, "", map[string]string{"COLLECTOR_STREAM": "false"}, []string{"--collector.stream"}).Settings()
+	if !s.CollectorEnabled("stream") {
+		t.Errorf("expected --collector.stream to enable the stream collector")
+	}
+}
@@ -0,0 +1,112 @@
+package httpServer
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/coolapso/prometheus-twitch-exporter/internal/collectors"
+)
+
+// Only allows requests with the given method and the admin bearer token
+func adminHandler(adminToken, method string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// Twitch failures are reported as bad gateway, everything else is a bad request
+func adminError(w http.ResponseWriter, err error) {
+	var tokenErr *collectors.TokenError
+	if errors.As(err, &tokenErr) {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Writes the details of the user token, or of the application token when
+// user is empty
+func writeTokenInfo(w http.ResponseWriter, e *collectors.Exporter, user string) {
+	info, err := e.TokenInfo(user)
+	if err != nil {
+		adminError(w, err)
+		return
+	}
+
+	writeJSON(w, info)
+}
+
+// Token administration endpoints, used to rotate credentials without
+// restarting the exporter
+func handleAdmin(mux *http.ServeMux, e *collectors.Exporter, adminToken string) {
+	logger := e.Logger
+
+	// Lists every token unless a user is given
+	mux.HandleFunc("/admin/token", adminHandler(adminToken, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("user") {
+			writeTokenInfo(w, e, r.URL.Query().Get("user"))
+			return
+		}
+
+		tokens, err := e.Tokens()
+		if err != nil {
+			adminError(w, err)
+			return
+		}
+
+		writeJSON(w, tokens)
+	}))
+
+	mux.HandleFunc("/admin/token/refresh", adminHandler(adminToken, http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		user := r.URL.Query().Get("user")
+		if err := e.ForceTokenRefresh(user); err != nil {
+			logger.Error("Failed to refresh token", "user", user, "err", err)
+			adminError(w, err)
+			return
+		}
+
+		logger.Info("Token refreshed by admin request", "user", user)
+		writeTokenInfo(w, e, user)
+	}))
+
+	mux.HandleFunc("/admin/token/revoke", adminHandler(adminToken, http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		user := r.URL.Query().Get("user")
+		if user == "" {
+			http.Error(w, "missing user parameter", http.StatusBadRequest)
+			return
+		}
+
+		if err := e.RevokeToken(user); err != nil {
+			logger.Error("Failed to revoke token", "user", user, "err", err)
+			adminError(w, err)
+			return
+		}
+
+		logger.Info("Token revoked by admin request", "user", user)
+		writeTokenInfo(w, e, user)
+	}))
+}
@@ -0,0 +1,252 @@
+package httpServer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/coolapso/prometheus-twitch-exporter/internal/collectors"
+	helix "github.com/nicklaw5/helix/v2"
+)
+
+const (
+	adminToken = "admin-secret"
+	appToken   = "app-secret"
+	userToken  = "user-secret"
+)
+
+// Sends every request to the fake twitch server regardless of the host
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// Answers of the fake twitch server
+type fakeTwitch struct {
+	tokenStatus    int
+	validateStatus int
+	login          string
+}
+
+// Creates an exporter with one user, cool4pso, and the admin token, backed
+// by the fake twitch
+func newTestExporter(t *testing.T, fake fakeTwitch) *collectors.Exporter {
+	t.Helper()
+
+	twitch := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/oauth2/token":
+			w.WriteHeader(fake.tokenStatus)
+			fmt.Fprintf(w, `{"access_token":%q,"refresh_token":"refresh","expires_in":3600,"token_type":"bearer"}`, appToken)
+		case "/oauth2/validate":
+			w.WriteHeader(fake.validateStatus)
+			fmt.Fprintf(w, `{"client_id":"clientID","login":%q,"user_id":"1","scopes":["user:read:email"],"expires_in":3600}`, fake.login)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(twitch.Close)
+
+	target, _ := url.Parse(twitch.URL)
+	e, err := collectors.NewExporter(&collectors.Settings{
+		ApiSettings: collectors.ApiSettings{Options: helix.Options{
+			ClientID:     "clientID",
+			ClientSecret: "clientSecret",
+			HTTPClient:   &http.Client{Transport: rewriteTransport{target}},
+		}},
+		UserToken:   true,
+		Users:       []collectors.TwitchUser{{Name: "cool4pso", AccessToken: userToken}},
+		MetricsPath: "/metrics",
+		AdminToken:  adminToken,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	return e
+}
+
+// Starts the admin endpoints of an exporter answering the token validation
+// with validateStatus
+func newAdminServer(t *testing.T, validateStatus int) *httptest.Server {
+	t.Helper()
+
+	e := newTestExporter(t, fakeTwitch{tokenStatus: http.StatusOK, validateStatus: validateStatus, login: "cool4pso"})
+	mux := http.NewServeMux()
+	handleAdmin(mux, e, adminToken)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestAdminEndpoints(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		validateStatus int
+		expectedStatus int
+		expectedHeader map[string]string
+		expectedBody   string
+	}{
+		{
+			name:           "Missing token",
+			method:         http.MethodGet,
+			path:           "/admin/token",
+			validateStatus: http.StatusOK,
+			expectedStatus: http.StatusUnauthorized,
+			expectedHeader: map[string]string{"WWW-Authenticate": "Bearer"},
+		},
+		{
+			name:           "Wrong token",
+			method:         http.MethodGet,
+			path:           "/admin/token",
+			token:          "wrong",
+			validateStatus: http.StatusOK,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Wrong method",
+			method:         http.MethodGet,
+			path:           "/admin/token/refresh",
+			token:          adminToken,
+			validateStatus: http.StatusOK,
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedHeader: map[string]string{"Allow": http.MethodPost},
+		},
+		{
+			name:           "Revoke without user",
+			method:         http.MethodPost,
+			path:           "/admin/token/revoke",
+			token:          adminToken,
+			validateStatus: http.StatusOK,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "missing user parameter",
+		},
+		{
+			name:           "List tokens",
+			method:         http.MethodGet,
+			path:           "/admin/token",
+			token:          adminToken,
+			validateStatus: http.StatusOK,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"login":"cool4pso"`,
+		},
+		{
+			name:           "Unknown user",
+			method:         http.MethodGet,
+			path:           "/admin/token?user=unknown",
+			token:          adminToken,
+			validateStatus: http.StatusOK,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Twitch failure",
+			method:         http.MethodGet,
+			path:           "/admin/token?user=cool4pso",
+			token:          adminToken,
+			validateStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusBadGateway,
+			expectedBody:   "validate user token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newAdminServer(t, tt.validateStatus)
+
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %v, got %v: %s", tt.expectedStatus, resp.StatusCode, body)
+			}
+
+			for name, value := range tt.expectedHeader {
+				if got := resp.Header.Get(name); got != value {
+					t.Errorf("expected header %v %q, got %q", name, value, got)
+				}
+			}
+
+			if !strings.Contains(string(body), tt.expectedBody) {
+				t.Errorf("expected body to contain %q, got %s", tt.expectedBody, body)
+			}
+
+			// The tokens themselves are never written
+			for _, secret := range []string{adminToken, appToken, userToken} {
+				if strings.Contains(string(body), secret) {
+					t.Errorf("expected no %q in the body, got %s", secret, body)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{
+			name:           "Token error",
+			err:            &collectors.TokenError{Op: "refresh user access token", StatusCode: http.StatusBadRequest, Message: "Invalid refresh token"},
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name:           "Wrapped token error",
+			err:            fmt.Errorf("Failed to refresh: %w", &collectors.TokenError{Op: "get app access token", Err: errors.New("connection refused")}),
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name:           "Other error",
+			err:            errors.New("Unknown user unknown"),
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			adminError(w, tt.err)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %v, got %v", tt.expectedStatus, w.Code)
+			}
+
+			if !strings.Contains(w.Body.String(), tt.err.Error()) {
+				t.Errorf("expected body %q, got %q", tt.err.Error(), w.Body.String())
+			}
+		})
+	}
+}
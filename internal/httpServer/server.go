@@ -1,13 +1,16 @@
 package httpServer
 
 import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+
 	"github.com/coolapso/prometheus-twitch-exporter/internal/collectors"
 	"github.com/prometheus/client_golang/prometheus"
 	promCollectors "github.com/prometheus/client_golang/prometheus/collectors"
 	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"html/template"
-	"net/http"
 )
 
 const (
@@ -17,18 +20,51 @@ const (
 		 <h1>Prometheus Twitch Exporter</h1>
 		 <p>Metrics at: <a href='{{ .MetricsPath }}'>{{ .MetricsPath }}</a></p>
 		 {{ if .UserToken }}
-		 <p>Authorize Prometheus twitch exporter <a href='{{ .ApiSettings.AuthorizationURL }}'>here</a></p>
+		 <table>
+			 <tr><th>User</th><th>Status</th><th>Token expires at</th><th></th></tr>
+			 {{ range .Users }}
+			 <tr>
+				 <td>{{ .Name }}</td>
+				 <td>{{ if not .Authorized }}not authorized{{ else if .Valid }}authorized{{ else }}token invalid{{ end }}</td>
+				 <td>{{ if not .ExpiresAt.IsZero }}{{ .ExpiresAt.Format "2006-01-02T15:04:05Z07:00" }}{{ end }}</td>
+				 <td><a href='{{ .AuthorizationURL }}'>Authorize Prometheus twitch exporter as {{ .Name }}</a></td>
+			 </tr>
+			 {{ end }}
+		 </table>
 		 {{ end }}
 		 <p>Source: <a href='https://github.com/coolapso/prometheus-twitch-exporter'>github.com/coolapso/prometheus-twitch-exporter</a></p>
 	 </body>
 	 </html>`
+
+	authorizedTemplate string = `<html>
+	 <head><title>Prometheus Twitch Exporter</title></head>
+	 <body>
+		 <h1>Prometheus Twitch Exporter</h1>
+		 <p>Exporter has been authorized by {{ .User }}</p>
+		 <p>Granted scopes: {{ range $i, $scope := .Scopes }}{{ if $i }}, {{ end }}{{ $scope }}{{ else }}none{{ end }}</p>
+		 <p>Token expires at: {{ .ExpiresAt.Format "2006-01-02T15:04:05Z07:00" }}</p>
+		 <p>Metrics at: <a href='{{ .MetricsPath }}'>{{ .MetricsPath }}</a></p>
+	 </body>
+	 </html>`
 )
 
+type rootPage struct {
+	MetricsPath string
+	UserToken   bool
+	Users       []collectors.UserStatus
+}
+
+type authorizedPage struct {
+	*collectors.Authorization
+	MetricsPath string
+}
+
 // TODO: Re-eneable remaining collectors
 func NewServer(e *collectors.Exporter) *http.Server {
 	s := e.Settings
 	logger := e.Logger
 	t := template.Must(template.New("root").Parse(rootTemplate))
+	at := template.Must(template.New("authorized").Parse(authorizedTemplate))
 
 	reg := prometheus.NewRegistry()
 	reg.MustRegister(
@@ -42,29 +78,98 @@ func NewServer(e *collectors.Exporter) *http.Server {
 		Registry: reg,
 	}
 
+	mux := http.NewServeMux()
+
 	// Metrics handler
-	http.Handle(s.MetricsPath, promhttp.HandlerFor(reg, promHandlerOpts))
+	mux.Handle(s.MetricsPath, promhttp.HandlerFor(reg, promHandlerOpts))
 
-	// Root Page handler
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		err := t.Execute(w, e.Settings)
+	// Metrics of a single channel, for channels discovered by prometheus
+	mux.HandleFunc("/probe", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		probe, err := e.Probe(query.Get("target"), query.Get("module"))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		probeReg := prometheus.NewRegistry()
+		probeReg.MustRegister(probe)
+		promhttp.HandlerFor(probeReg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+
+	// Reloads the channel list and collector settings, with the admin token
+	// when one is set
+	reload := func(w http.ResponseWriter, r *http.Request) {
+		if err := e.Reload(); err != nil {
+			http.Error(w, fmt.Sprintf("failed to reload settings: %v", err), http.StatusInternalServerError)
 		}
+	}
+
+	if s.AdminToken != "" {
+		mux.HandleFunc("/-/reload", adminHandler(s.AdminToken, http.MethodPost, reload))
+	} else {
+		mux.HandleFunc("/-/reload", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				w.Header().Set("Allow", http.MethodPost)
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+
+			reload(w, r)
+		})
+	}
+
+	if s.AdminToken != "" {
+		handleAdmin(mux, e, s.AdminToken)
+	} else {
+		logger.Debug("Admin token not set, admin endpoints disabled")
+	}
 
+	// Root Page handler, also used as the oauth redirect uri
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		query := r.URL.Query()
-		if len(query) > 0 {
-			if len(query["code"]) > 0 {
-				s.ApiSettings.AuthorizationCode = query["code"][0]
-				logger.Info("Prometheus Twitch Exporter authorized by user")
-				_, err := w.Write([]byte("exporter has been authorized"))
-				if err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-				}
+		if authErr := query.Get("error"); authErr != "" {
+			logger.Warn("Prometheus Twitch Exporter authorization failed", "err", authErr, "description", query.Get("error_description"))
+			http.Error(w, fmt.Sprintf("authorization failed: %v %v", authErr, query.Get("error_description")), http.StatusBadRequest)
+			return
+		}
+
+		if code := query.Get("code"); code != "" && s.UserToken {
+			authorization, err := e.Authorize(query.Get("state"), code)
+			if err != nil {
+				logger.Error("Failed to authorize Prometheus Twitch Exporter", "err", err)
+				http.Error(w, err.Error(), authorizeStatus(err))
+				return
 			}
+
+			logger.Info("Prometheus Twitch Exporter authorized by user", "user", authorization.User, "scopes", authorization.Scopes, "expiresAt", authorization.ExpiresAt)
+			err = at.Execute(w, authorizedPage{authorization, s.MetricsPath})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
 		}
 
+		err := t.Execute(w, rootPage{s.MetricsPath, s.UserToken, e.UserStatus()})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 	})
 
-	return &http.Server{Addr: ":" + s.ListenPort}
+	return &http.Server{Addr: ":" + s.ListenPort, Handler: mux}
+}
+
+// Twitch failures are reported as bad gateway, a token of another twitch
+// account as forbidden and everything else as a bad request
+func authorizeStatus(err error) int {
+	var tokenErr *collectors.TokenError
+	var loginErr *collectors.LoginMismatchError
+	switch {
+	case errors.As(err, &tokenErr):
+		return http.StatusBadGateway
+	case errors.As(err, &loginErr):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
 }
@@ -0,0 +1,129 @@
+package httpServer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coolapso/prometheus-twitch-exporter/internal/collectors"
+)
+
+func TestAuthorizeRedirect(t *testing.T) {
+	tests := []struct {
+		name           string
+		state          string
+		twitch         fakeTwitch
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Authorized",
+			state:          "cool4pso",
+			twitch:         fakeTwitch{tokenStatus: http.StatusOK, validateStatus: http.StatusOK, login: "cool4pso"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "Exporter has been authorized by cool4pso",
+		},
+		{
+			name:           "Unknown user",
+			state:          "unknown",
+			twitch:         fakeTwitch{tokenStatus: http.StatusOK, validateStatus: http.StatusOK, login: "cool4pso"},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "User unknown not configured",
+		},
+		{
+			name:           "Login of another account",
+			state:          "cool4pso",
+			twitch:         fakeTwitch{tokenStatus: http.StatusOK, validateStatus: http.StatusOK, login: "someone"},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   "Token belongs to someone",
+		},
+		{
+			name:           "Twitch failure",
+			state:          "cool4pso",
+			twitch:         fakeTwitch{tokenStatus: http.StatusBadRequest, validateStatus: http.StatusOK, login: "cool4pso"},
+			expectedStatus: http.StatusBadGateway,
+			expectedBody:   "exchange authorization code",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(newTestExporter(t, tt.twitch))
+
+			w := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?code=code&state="+tt.state, nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %v, got %v: %s", tt.expectedStatus, w.Code, w.Body)
+			}
+
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("expected body to contain %q, got %s", tt.expectedBody, w.Body)
+			}
+		})
+	}
+}
+
+func TestReloadEndpoint(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		token          string
+		expectedStatus int
+		expectedReload bool
+	}{
+		{
+			name:           "Missing admin token",
+			method:         http.MethodPost,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Wrong admin token",
+			method:         http.MethodPost,
+			token:          "wrong",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Wrong method",
+			method:         http.MethodGet,
+			token:          adminToken,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "Reload",
+			method:         http.MethodPost,
+			token:          adminToken,
+			expectedStatus: http.StatusOK,
+			expectedReload: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestExporter(t, fakeTwitch{tokenStatus: http.StatusOK, validateStatus: http.StatusOK, login: "cool4pso"})
+			reloaded := false
+			e.LoadSettings = func() (*collectors.Settings, error) {
+				reloaded = true
+				return &collectors.Settings{}, nil
+			}
+			srv := NewServer(e)
+
+			req := httptest.NewRequest(tt.method, "/-/reload", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			w := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %v, got %v: %s", tt.expectedStatus, w.Code, w.Body)
+			}
+
+			if reloaded != tt.expectedReload {
+				t.Errorf("expected reload %v, got %v", tt.expectedReload, reloaded)
+			}
+		})
+	}
+}